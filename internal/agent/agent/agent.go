@@ -6,6 +6,9 @@ import (
 	"errors"
 	"fmt"
 	"reddit-analyzer/internal/agent/llm"
+	"reddit-analyzer/internal/agent/llm/tokenizer"
+	"strings"
+	"sync"
 
 	"github.com/invopop/jsonschema"
 	"github.com/xeipuuv/gojsonschema"
@@ -20,8 +23,16 @@ var (
 	ErrInvalidResultSchema = errors.New("invalid result schema")
 	ErrCannotCreateSchema  = errors.New("cannot create schema from output type")
 	ErrEmptySystemPrompt   = errors.New("system prompt cannot be empty")
+	ErrTooManyToolFailures = errors.New("too many consecutive tool failures")
+	ErrToolPanic           = errors.New("tool panicked")
 )
 
+const limitReachedPrompt = "All tools reached their usage limits. Do not call tools anymore and return the final JSON object that matches the output schema."
+
+const responseSchemaName = "agent_result"
+
+const repairPrompt = "The final answer does not match the output schema: %s. Return only the corrected JSON object that matches the output schema."
+
 var systemPromptTemplate = NewPrompt(`You are an agent that should act as specified in escaped content <BEHAVIOR></BEHAVIOR>.
 At the end of execution when you will be read to finish, you should return a JSON object that matches the output schema.
 
@@ -52,6 +63,24 @@ type Agent[T any] struct {
 	systemPrompt Prompt
 	behavior     string
 	schemaLoader gojsonschema.JSONLoader
+	budget       Budget
+	price        llm.LLMPrice
+	prices       llm.LLMPriceTable
+
+	recoverToolErrors bool
+	maxToolFailures   int
+	repairAttempts    int
+	maxParallelTools  int
+	// responseSchema is sent to LLMs that support native structured outputs on
+	// the turns that must return the final answer.
+	responseSchema       map[string]any
+	responseSchemaStrict bool
+	observers            []AgentObserver
+	// onDelta receives the content deltas of streamed runs.
+	onDelta       func(delta string)
+	checkpoints   CheckpointStore
+	contextWindow *contextWindow
+	tokenizer     *tokenizer.Tokenizer
 }
 
 type AgentOption[T any] func(*Agent[T])
@@ -66,11 +95,13 @@ func NewAgent[T any](options ...AgentOption[T]) (*Agent[T], error) {
 		opt(agent)
 	}
 
-	agentLLM, err := llm.CreateLLM(agent.llmConfig, agent.tools)
-	if err != nil {
-		return nil, err
+	if agent.llm == nil {
+		agentLLM, err := llm.CreateLLM(agent.llmConfig, agent.tools)
+		if err != nil {
+			return nil, err
+		}
+		agent.llm = agentLLM
 	}
-	agent.llm = agentLLM
 
 	return agent, nil
 }
@@ -87,6 +118,13 @@ func WithLLMConfig[T any](config llm.LLMConfig) AgentOption[T] {
 	}
 }
 
+// WithLLM sets the LLM used by the agent instead of creating one from the LLM config.
+func WithLLM[T any](agentLLM llm.LLM) AgentOption[T] {
+	return func(a *Agent[T]) {
+		a.llm = agentLLM
+	}
+}
+
 func WithBehavior[T any](behavior string) AgentOption[T] {
 	return func(a *Agent[T]) {
 		a.behavior = behavior
@@ -102,9 +140,73 @@ func WithOutputSchema[T any](schema *T) AgentOption[T] {
 		if err == nil {
 			a.schemaLoader = gojsonschema.NewStringLoader(string(schemaBytes))
 		}
+		a.responseSchema = createResponseSchema(schema)
+		a.responseSchemaStrict = a.responseSchema != nil && prepareStrictSchema(a.responseSchema)
 	}
 }
 
+// createResponseSchema reflects the output type into a schema with an object at
+// its root, as required by native structured outputs.
+func createResponseSchema(schema any) map[string]any {
+	reflector := jsonschema.Reflector{ExpandedStruct: true}
+	schemaBytes, err := json.Marshal(reflector.Reflect(schema))
+	if err != nil {
+		return nil
+	}
+
+	var responseSchema map[string]any
+	if err := json.Unmarshal(schemaBytes, &responseSchema); err != nil {
+		return nil
+	}
+	delete(responseSchema, "$schema")
+	return responseSchema
+}
+
+// prepareStrictSchema closes the objects of the schema to additional
+// properties and reports whether the schema can be enforced in strict mode,
+// which requires every object to declare and require all of its properties.
+// Optional fields, maps and values of any type cannot be enforced.
+func prepareStrictSchema(schema any) bool {
+	node, ok := schema.(map[string]any)
+	if !ok {
+		// the true schema accepts any value
+		return false
+	}
+	if _, ok := node["oneOf"]; ok {
+		return false
+	}
+	if _, ok := node["allOf"]; ok {
+		return false
+	}
+
+	strict := true
+	if node["type"] == "object" {
+		properties, _ := node["properties"].(map[string]any)
+		required, _ := node["required"].([]any)
+		additional, exists := node["additionalProperties"]
+		if !exists {
+			node["additionalProperties"] = false
+		}
+		if len(properties) == 0 || len(required) != len(properties) || (exists && additional != false) {
+			strict = false
+		}
+	}
+	for _, key := range []string{"properties", "$defs"} {
+		children, _ := node[key].(map[string]any)
+		for _, child := range children {
+			strict = prepareStrictSchema(child) && strict
+		}
+	}
+	if items, ok := node["items"]; ok {
+		strict = prepareStrictSchema(items) && strict
+	}
+	anyOf, _ := node["anyOf"].([]any)
+	for _, child := range anyOf {
+		strict = prepareStrictSchema(child) && strict
+	}
+	return strict
+}
+
 func WithSystemPrompt[T any](prompt Prompt) AgentOption[T] {
 	return func(a *Agent[T]) {
 		a.systemPrompt = prompt
@@ -123,54 +225,326 @@ func WithToolLimit[T any](name string, limit int) AgentOption[T] {
 	}
 }
 
+// WithBudget limits every run of the agent. Budgets passed to Run with
+// WithRunBudget take precedence.
+func WithBudget[T any](budget Budget) AgentOption[T] {
+	return func(a *Agent[T]) {
+		a.budget = budget
+	}
+}
+
+// WithLLMPrice sets the token price used to estimate the cost of runs and to
+// enforce Budget.MaxCost.
+func WithLLMPrice[T any](price llm.LLMPrice) AgentOption[T] {
+	return func(a *Agent[T]) {
+		a.price = price
+	}
+}
+
+// WithToolErrorRecovery reports failed tool calls, unknown tools and invalid
+// arguments back to the model instead of aborting the run. The run fails with
+// ErrTooManyToolFailures after more than maxConsecutiveFailures failed calls
+// in a row; zero disables the cap.
+func WithToolErrorRecovery[T any](maxConsecutiveFailures int) AgentOption[T] {
+	return func(a *Agent[T]) {
+		a.recoverToolErrors = true
+		a.maxToolFailures = maxConsecutiveFailures
+	}
+}
+
+// WithOutputRepairAttempts lets the model correct a final answer that does not
+// match the output schema up to attempts times before the run fails.
+func WithOutputRepairAttempts[T any](attempts int) AgentOption[T] {
+	return func(a *Agent[T]) {
+		a.repairAttempts = attempts
+	}
+}
+
+// WithParallelToolCalls runs the tool calls of a single LLM message
+// concurrently, at most maxParallel at once.
+func WithParallelToolCalls[T any](maxParallel int) AgentOption[T] {
+	return func(a *Agent[T]) {
+		a.maxParallelTools = maxParallel
+	}
+}
+
 type AgentState struct {
-	Messages []llm.LLMMessage
+	RunID        string           `json:"run_id"`
+	Messages     []llm.LLMMessage `json:"messages"`
+	ToolUsage    map[string]int   `json:"tool_usage"`
+	ToolFailures int              `json:"tool_failures"`
+	Iterations   int              `json:"iterations"`
+	Repairs      int              `json:"repairs"`
+	Usage        llm.LLMUsage     `json:"usage"`
+	Cost         float64          `json:"cost"`
+	Turns        []TurnUsage      `json:"turns"`
+	// LimitReached is set once every tool has used up its limit. The run then
+	// asks for the final answer and ends with ErrLimitReached.
+	LimitReached bool `json:"limit_reached,omitempty"`
+	// FinalTurn is set after the limit or a repair prompt. The next LLM calls
+	// must return the final answer and run without tools.
+	FinalTurn bool `json:"final_turn,omitempty"`
 }
 
 func (a *AgentState) AddMessage(msg llm.LLMMessage) {
 	a.Messages = append(a.Messages, msg)
 }
 
-func (a *Agent[T]) Run(ctx context.Context, input any) (*AgentResult[T], error) {
+func (a *Agent[T]) Run(ctx context.Context, input any, options ...RunOption) (*AgentResult[T], error) {
+	runOpts := newRunOptions(options...)
+
 	state, err := a.createInitState(input)
 	if err != nil {
 		return nil, err
 	}
-	usage := make(map[string]int)
+	state.RunID = runOpts.runID
+	if state.RunID == "" {
+		state.RunID = newRunID()
+	}
+	if err := a.saveCheckpoint(ctx, state); err != nil {
+		return nil, err
+	}
+
+	return a.execute(ctx, state, runOpts, false)
+}
+
+// Resume continues the checkpointed run. Tool calls that already have results
+// are not executed again.
+func (a *Agent[T]) Resume(ctx context.Context, runID string, options ...RunOption) (*AgentResult[T], error) {
+	if a.checkpoints == nil {
+		return nil, fmt.Errorf("%w: no checkpoint store configured", ErrCheckpoint)
+	}
+	state, err := a.checkpoints.Load(ctx, runID)
+	if err != nil {
+		return nil, err
+	}
+
+	return a.execute(ctx, state, newRunOptions(options...), true)
+}
+
+func (a *Agent[T]) execute(ctx context.Context, state *AgentState, runOpts *runOptions, resume bool) (*AgentResult[T], error) {
+	budget := a.budget.merge(runOpts.budget)
+	if budget.MaxDuration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, budget.MaxDuration, ErrMaxDurationReached)
+		defer cancel()
+	}
+
+	a.notify(func(o AgentObserver) { o.OnRunStart(ctx, state) })
+	if !resume {
+		// new runs and session turns start with a freshly rendered prompt
+		a.notify(func(o AgentObserver) { o.OnSystemPromptRendered(ctx, state.Messages[0].Content) })
+	}
+	res, err := a.resumeOrLoop(ctx, state, budget, resume)
+	a.notify(func(o AgentObserver) { o.OnRunFinish(ctx, state, err) })
+	return res, err
+}
 
+// resumeOrLoop finishes the last step of a resumed run, whose state ends with
+// an LLM response, before continuing the loop.
+func (a *Agent[T]) resumeOrLoop(ctx context.Context, state *AgentState, budget Budget, resume bool) (*AgentResult[T], error) {
+	if resume && state.Messages[len(state.Messages)-1].Type == llm.LLMMessageTypeAssistant {
+		res, done, err := a.handleResponse(ctx, state)
+		if done {
+			return res, err
+		}
+		if err := a.saveCheckpoint(ctx, state); err != nil {
+			return nil, err
+		}
+	}
+	return a.loop(ctx, state, budget)
+}
+
+// loop calls the LLM and handles its responses until the LLM returns the final
+// answer or the run fails. The state is checkpointed after the LLM response
+// and after it is handled, so a resumed run continues a final turn too.
+func (a *Agent[T]) loop(ctx context.Context, state *AgentState, budget Budget) (*AgentResult[T], error) {
 	for {
-		// if a.isLimitReached(usage) {
-		// 	res, err := a.createResult(state)
-		// 	if err != nil {
-		// 		return nil, fmt.Errorf("%w: %s", ErrLimitReached, err)
-		// 	}
-		// 	return res, ErrLimitReached
-		// }
-
-		llmMessage, err := a.llm.Call(ctx, state.Messages)
+		llmMessage, err := a.callLLM(ctx, state, budget, a.turnOptions(state)...)
 		if err != nil {
-			return nil, fmt.Errorf("%w: %s", ErrLLMCall, err)
+			if state.LimitReached {
+				return partialResult[T](state), fmt.Errorf("%w: %w", ErrLimitReached, err)
+			}
+			return nil, err
+		}
+		state.AddMessage(llmMessage)
+		if err := a.saveCheckpoint(ctx, state); err != nil {
+			return nil, err
 		}
 
-		if llmMessage.ToolCalls != nil {
-			results, err := a.callTools(llmMessage, usage)
-			if err != nil {
-				return nil, fmt.Errorf("%w: %s", ErrToolError, err)
-			}
-			llmMessage.ToolResults = results
+		res, done, err := a.handleResponse(ctx, state)
+		if done {
+			return res, err
 		}
+		if err := a.saveCheckpoint(ctx, state); err != nil {
+			return nil, err
+		}
+	}
+}
 
-		state.AddMessage(llmMessage)
+// turnOptions attaches the response schema to every turn when the LLM config
+// enables structured outputs, since the schema does not stop the model from
+// calling tools. Otherwise only the turns that must return the final answer
+// get it. Final turns run without tools.
+func (a *Agent[T]) turnOptions(state *AgentState) []llm.LLMCallOption {
+	var options []llm.LLMCallOption
+	if state.FinalTurn {
+		options = append(options, llm.WithLLMCallToolsDisabled())
+	}
+
+	final := state.FinalTurn || len(a.tools) == 0
+	switch {
+	case a.responseSchema == nil || !(final || a.llmConfig.StructuredOutputs):
+	case a.responseSchemaStrict:
+		options = append(options, llm.WithLLMCallStrictResponseSchema(responseSchemaName, a.responseSchema))
+	default:
+		options = append(options, llm.WithLLMCallResponseSchema(responseSchemaName, a.responseSchema))
+	}
+	return options
+}
 
-		if llmMessage.End {
-			return a.createResult(state)
+// handleResponse executes the pending tool calls of the last LLM response and
+// returns done once the run has a result or has failed.
+func (a *Agent[T]) handleResponse(ctx context.Context, state *AgentState) (res *AgentResult[T], done bool, err error) {
+	llmMessage := &state.Messages[len(state.Messages)-1]
+
+	pending := pendingToolCalls(*llmMessage)
+	if len(pending) > 0 {
+		results, err := a.callTools(ctx, pending, state)
+		if err != nil {
+			if errors.Is(context.Cause(ctx), ErrMaxDurationReached) {
+				return nil, true, &BudgetExceededError{Err: ErrMaxDurationReached, State: state}
+			}
+			return nil, true, err
 		}
+		llmMessage.ToolResults = append(llmMessage.ToolResults, results...)
+	}
 
-		newSystemPrompt, err := a.createSystemPrompt(usage)
+	if llmMessage.End {
+		res, err := a.validateResult(ctx, state)
+		if errors.Is(err, ErrInvalidResultSchema) && state.Repairs < a.repairAttempts {
+			state.Repairs++
+			state.FinalTurn = true
+			state.AddMessage(llm.NewLLMMessage(llm.LLMMessageTypeUser, fmt.Sprintf(repairPrompt, err)))
+			return nil, false, nil
+		}
+		if !state.LimitReached {
+			return res, true, err
+		}
 		if err != nil {
-			return nil, fmt.Errorf("failed to update system prompt: %w", err)
+			return partialResult[T](state), true, fmt.Errorf("%w: %w", ErrLimitReached, err)
 		}
-		state.Messages[0].Content = newSystemPrompt
+		return res, true, ErrLimitReached
+	}
+
+	newSystemPrompt, err := a.createSystemPrompt(state.ToolUsage)
+	if err != nil {
+		return nil, true, fmt.Errorf("failed to update system prompt: %w", err)
+	}
+	state.Messages[0].Content = newSystemPrompt
+	a.notify(func(o AgentObserver) { o.OnSystemPromptRendered(ctx, newSystemPrompt) })
+
+	if len(pending) > 0 && !state.LimitReached && a.isLimitReached(state.ToolUsage) {
+		state.LimitReached = true
+		state.FinalTurn = true
+		state.AddMessage(llm.NewLLMMessage(llm.LLMMessageTypeUser, limitReachedPrompt))
+	}
+	return nil, false, nil
+}
+
+// pendingToolCalls returns the tool calls of the message that have no result
+// yet.
+func pendingToolCalls(llmMessage llm.LLMMessage) []llm.LLMToolCall {
+	done := make(map[string]bool, len(llmMessage.ToolResults))
+	for _, result := range llmMessage.ToolResults {
+		done[result.GetID()] = true
+	}
+
+	var pending []llm.LLMToolCall
+	for _, toolCall := range llmMessage.ToolCalls {
+		if !done[toolCall.ID] {
+			pending = append(pending, toolCall)
+		}
+	}
+	return pending
+}
+
+// callLLM checks the budget and compacts the context before calling the LLM
+// and accounts the call in the state afterwards.
+func (a *Agent[T]) callLLM(ctx context.Context, state *AgentState, budget Budget, options ...llm.LLMCallOption) (llm.LLMMessage, error) {
+	if err := a.checkBudget(ctx, state, budget); err != nil {
+		return llm.LLMMessage{}, err
+	}
+	if err := a.compactContext(ctx, state); err != nil {
+		return llm.LLMMessage{}, err
+	}
+	// a summarizing strategy may have used up the budget
+	if err := a.checkBudget(ctx, state, budget); err != nil {
+		return llm.LLMMessage{}, err
+	}
+
+	a.notify(func(o AgentObserver) { o.OnLLMCallStart(ctx, state.Messages) })
+	llmMessage, err := a.invokeLLM(ctx, state.Messages, options...)
+	a.notify(func(o AgentObserver) { o.OnLLMCallEnd(ctx, llmMessage, err) })
+	if err != nil {
+		if errors.Is(context.Cause(ctx), ErrMaxDurationReached) {
+			return llm.LLMMessage{}, &BudgetExceededError{Err: ErrMaxDurationReached, State: state}
+		}
+		return llm.LLMMessage{}, fmt.Errorf("%w: %s", ErrLLMCall, err)
+	}
+
+	state.Iterations++
+	a.recordUsage(state, llmMessage)
+	return llmMessage, nil
+}
+
+// invokeLLM streams the response when the run reports content deltas and
+// makes a blocking call otherwise.
+func (a *Agent[T]) invokeLLM(ctx context.Context, msgs []llm.LLMMessage, options ...llm.LLMCallOption) (llm.LLMMessage, error) {
+	if a.onDelta == nil {
+		return a.llm.Call(ctx, msgs, options...)
+	}
+
+	stream, err := a.llm.Stream(ctx, msgs, options...)
+	if err != nil {
+		return llm.LLMMessage{}, err
+	}
+	defer stream.Close()
+
+	for stream.Next() {
+		if event := stream.Current(); event.Type == llm.LLMStreamEventTypeContent {
+			a.onDelta(event.Delta)
+		}
+	}
+	if err := stream.Err(); err != nil {
+		return llm.LLMMessage{}, err
+	}
+	return stream.Message(), nil
+}
+
+func (a *Agent[T]) checkBudget(ctx context.Context, state *AgentState, budget Budget) error {
+	var err error
+	switch {
+	case budget.MaxIterations > 0 && state.Iterations >= budget.MaxIterations:
+		err = ErrMaxIterationsReached
+	case budget.MaxTokens > 0 && state.Usage.TotalTokens >= budget.MaxTokens:
+		err = ErrMaxTokensReached
+	case budget.MaxCost > 0 && state.Cost >= budget.MaxCost:
+		err = ErrMaxCostReached
+	case errors.Is(context.Cause(ctx), ErrMaxDurationReached):
+		err = ErrMaxDurationReached
+	default:
+		return nil
+	}
+	return &BudgetExceededError{Err: err, State: state}
+}
+
+// partialResult returns the result of a run that ended without valid data.
+func partialResult[T any](state *AgentState) *AgentResult[T] {
+	return &AgentResult[T]{
+		RunID:    state.RunID,
+		Messages: state.Messages,
+		Usage:    newAgentUsage(state.Turns),
 	}
 }
 
@@ -194,6 +568,7 @@ func (a *Agent[T]) createInitState(input any) (*AgentState, error) {
 			llm.NewLLMMessage(llm.LLMMessageTypeSystem, systemPrompt),
 			llm.NewLLMMessage(llm.LLMMessageTypeUser, string(inputJson)),
 		},
+		ToolUsage: make(map[string]int),
 	}, nil
 }
 
@@ -228,24 +603,187 @@ func (a *Agent[T]) createSystemPrompt(usage map[string]int) (string, error) {
 	})
 }
 
-func (a *Agent[T]) callTools(llmMessage llm.LLMMessage, usage map[string]int) ([]llm.LLMToolResult, error) {
-	var results []llm.LLMToolResult
-	for _, toolCall := range llmMessage.ToolCalls {
-		tool, ok := a.tools[toolCall.ToolName]
-		if !ok {
-			return nil, fmt.Errorf("%w: %s", ErrToolNotFound, toolCall.ToolName)
+type toolCallJob struct {
+	index    int
+	toolCall llm.LLMToolCall
+	result   llm.LLMToolResult
+	err      error
+}
+
+// callTools executes the tool calls. Calls over the tool limit are not
+// executed; the model receives a limit reached error result instead. Calls
+// with arguments that do not match the tool schema are reported to the model
+// as error results, and so are failed calls when tool error recovery is
+// enabled. Results keep the order of the tool calls,
+// and so does the count of consecutive failures.
+func (a *Agent[T]) callTools(ctx context.Context, toolCalls []llm.LLMToolCall, state *AgentState) ([]llm.LLMToolResult, error) {
+	results := make([]llm.LLMToolResult, len(toolCalls))
+	// calls holds the outcome of every call that was not refused
+	calls := make([]*toolCallJob, len(toolCalls))
+	jobs := make([]*toolCallJob, 0, len(toolCalls))
+	reserved := make(map[string]int)
+	for i, toolCall := range toolCalls {
+		limit, exists := a.limits[toolCall.ToolName]
+		if exists && state.ToolUsage[toolCall.ToolName]+reserved[toolCall.ToolName] >= limit {
+			results[i] = llm.NewLLMToolErrorResult(
+				toolCall.ID,
+				fmt.Errorf("%w: tool = %s, limit = %d", ErrLimitReached, toolCall.ToolName, limit),
+			)
+			continue
 		}
-		toolRes, err := tool.Call(toolCall.ID, toolCall.Args)
-		if err != nil {
-			return nil, fmt.Errorf("%w: %s", ErrToolError, err)
+		job := &toolCallJob{index: i, toolCall: toolCall}
+		calls[i] = job
+		if job.err = a.validateToolCall(&job.toolCall); job.err != nil {
+			continue
 		}
-		usage[toolCall.ToolName]++
-		results = append(results, toolRes)
+		reserved[toolCall.ToolName]++
+		jobs = append(jobs, job)
+	}
+
+	if err := a.runToolCallJobs(ctx, jobs); err != nil {
+		return nil, err
+	}
+
+	for _, job := range calls {
+		if job == nil {
+			continue
+		}
+		if job.err != nil {
+			if err := a.recordToolFailure(state, job.err); err != nil {
+				return nil, err
+			}
+			results[job.index] = llm.NewLLMToolErrorResult(job.toolCall.ID, job.err)
+			continue
+		}
+		state.ToolFailures = 0
+		state.ToolUsage[job.toolCall.ToolName]++
+		results[job.index] = job.result
 	}
 
 	return results, nil
 }
 
+// validateToolCall parses the arguments sent by the model and checks them
+// against the parameters schema of the tool. Unknown tools are reported when
+// the call is executed.
+func (a *Agent[T]) validateToolCall(toolCall *llm.LLMToolCall) error {
+	tool, ok := a.tools[toolCall.ToolName]
+	if !ok {
+		return nil
+	}
+	args, err := toolCall.ParseArgs()
+	if err != nil {
+		return fmt.Errorf("tool = %s: %w", toolCall.ToolName, err)
+	}
+	toolCall.Args = args
+	if err := llm.ValidateLLMToolArgs(tool.ParametersSchema, args); err != nil {
+		return fmt.Errorf("tool = %s: %w", toolCall.ToolName, err)
+	}
+	return nil
+}
+
+// recordToolFailure counts a failed tool call and returns ErrTooManyToolFailures
+// once the consecutive failure cap is exceeded.
+func (a *Agent[T]) recordToolFailure(state *AgentState, err error) error {
+	state.ToolFailures++
+	if a.maxToolFailures > 0 && state.ToolFailures > a.maxToolFailures {
+		return fmt.Errorf("%w: failures = %d: %w", ErrTooManyToolFailures, state.ToolFailures, err)
+	}
+	return nil
+}
+
+// runToolCallJobs executes the jobs, at most maxParallelTools at once. Without
+// tool error recovery the first failed job is fatal: the other jobs are
+// cancelled and its error is returned.
+func (a *Agent[T]) runToolCallJobs(ctx context.Context, jobs []*toolCallJob) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		fatalErr error
+	)
+	slots := make(chan struct{}, max(a.maxParallelTools, 1))
+	for _, job := range jobs {
+		slots <- struct{}{}
+		if ctx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+		go func(job *toolCallJob) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			a.notify(func(o AgentObserver) { o.OnToolCallStart(ctx, job.toolCall) })
+			job.result, job.err = a.callTool(ctx, job.toolCall)
+			a.notify(func(o AgentObserver) { o.OnToolCallEnd(ctx, job.toolCall, job.result, job.err) })
+			if job.err != nil && !a.recoverToolErrors {
+				errOnce.Do(func() {
+					fatalErr = job.err
+					cancel()
+				})
+			}
+		}(job)
+	}
+	wg.Wait()
+
+	if fatalErr != nil {
+		return fatalErr
+	}
+	return context.Cause(ctx)
+}
+
+type toolOutcome struct {
+	result llm.LLMToolResult
+	err    error
+}
+
+// callTool runs the tool in its own goroutine, so a cancelled run or an
+// exceeded tool timeout returns even if the tool ignores the context. A panic
+// in the tool is returned as an error.
+func (a *Agent[T]) callTool(ctx context.Context, toolCall llm.LLMToolCall) (llm.LLMToolResult, error) {
+	tool, ok := a.tools[toolCall.ToolName]
+	if !ok {
+		return nil, fmt.Errorf("%w: %w: %s", ErrToolError, ErrToolNotFound, toolCall.ToolName)
+	}
+
+	if tool.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, tool.Timeout)
+		defer cancel()
+	}
+
+	done := make(chan toolOutcome, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- toolOutcome{err: fmt.Errorf("%w: %v", ErrToolPanic, r)}
+			}
+		}()
+		result, err := tool.Call(ctx, toolCall.ID, toolCall.Args)
+		done <- toolOutcome{result: result, err: err}
+	}()
+
+	select {
+	case outcome := <-done:
+		if outcome.err != nil {
+			return nil, fmt.Errorf("%w: tool = %s: %w", ErrToolError, toolCall.ToolName, outcome.err)
+		}
+		return outcome.result, nil
+	case <-ctx.Done():
+		return nil, fmt.Errorf("%w: tool = %s: %w", ErrToolError, toolCall.ToolName, context.Cause(ctx))
+	}
+}
+
+func (a *Agent[T]) validateResult(ctx context.Context, state *AgentState) (*AgentResult[T], error) {
+	res, err := a.createResult(state)
+	content := state.Messages[len(state.Messages)-1].Content
+	a.notify(func(o AgentObserver) { o.OnOutputValidated(ctx, content, err) })
+	return res, err
+}
+
 func (a *Agent[T]) createResult(state *AgentState) (*AgentResult[T], error) {
 	dataLoader := gojsonschema.NewStringLoader(state.Messages[len(state.Messages)-1].Content)
 	validationRes, err := gojsonschema.Validate(a.schemaLoader, dataLoader)
@@ -254,27 +792,43 @@ func (a *Agent[T]) createResult(state *AgentState) (*AgentResult[T], error) {
 		return nil, fmt.Errorf("%w: %s", ErrInvalidResultSchema, err)
 	}
 	if !validationRes.Valid() {
-		return nil, fmt.Errorf("%w: %s", ErrInvalidResultSchema, validationRes.Errors())
+		return nil, fmt.Errorf("%w: %s", ErrInvalidResultSchema, formatValidationErrors(validationRes.Errors()))
 	}
 
 	var data T
 
 	if err := json.Unmarshal([]byte(state.Messages[len(state.Messages)-1].Content), &data); err != nil {
-		return nil, err
+		return nil, fmt.Errorf("%w: %s", ErrInvalidResultSchema, err)
 	}
 
 	return &AgentResult[T]{
+		RunID:    state.RunID,
 		Data:     &data,
 		Messages: state.Messages,
+		Repairs:  state.Repairs,
+		Usage:    newAgentUsage(state.Turns),
 	}, nil
 }
 
+func formatValidationErrors(resultErrors []gojsonschema.ResultError) string {
+	descriptions := make([]string, 0, len(resultErrors))
+	for _, resultError := range resultErrors {
+		descriptions = append(descriptions, resultError.String())
+	}
+	return strings.Join(descriptions, "; ")
+}
+
+// isLimitReached reports whether every tool has used up its limit. Tools
+// without a limit never do. Tools that were never called count as unused, so
+// a zero limit is reached from the start.
 func (a *Agent[T]) isLimitReached(usage map[string]int) bool {
-	limitReached := make(map[string]bool)
-	for toolName, limit := range a.limits {
-		if usage, exists := usage[toolName]; exists && usage >= limit {
-			limitReached[toolName] = true
+	if len(a.tools) == 0 {
+		return false
+	}
+	for toolName := range a.tools {
+		if limit, exists := a.limits[toolName]; !exists || usage[toolName] < limit {
+			return false
 		}
 	}
-	return len(limitReached) == len(a.limits)
+	return true
 }
@@ -6,8 +6,11 @@ import (
 )
 
 type AgentResult[T any] struct {
+	RunID    string           `json:"run_id"`
 	Data     *T               `json:"data"`
 	Messages []llm.LLMMessage `json:"messages"`
+	Repairs  int              `json:"repairs"`
+	Usage    AgentUsage       `json:"usage"`
 }
 
 func NewAgentResult[T any](data *T, messages []llm.LLMMessage) (*AgentResult[T], error) {
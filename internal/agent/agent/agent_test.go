@@ -2,8 +2,10 @@ package agent_test
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -57,6 +59,50 @@ func createAddTool() llm.LLMTool {
 	)
 }
 
+// scriptedLLM replies with the scripted messages in order and records the
+// options of every call.
+type scriptedLLM struct {
+	responses []llm.LLMMessage
+	calls     []llm.LLMCallOptions
+}
+
+func newScriptedLLM(responses ...llm.LLMMessage) *scriptedLLM {
+	return &scriptedLLM{responses: responses}
+}
+
+func (s *scriptedLLM) Call(ctx context.Context, msgs []llm.LLMMessage, options ...llm.LLMCallOption) (llm.LLMMessage, error) {
+	s.calls = append(s.calls, llm.NewLLMCallOptions(options...))
+	if len(s.responses) == 0 {
+		return llm.LLMMessage{}, errors.New("no scripted response")
+	}
+	response := s.responses[0]
+	s.responses = s.responses[1:]
+	return response, nil
+}
+
+func (s *scriptedLLM) Stream(ctx context.Context, msgs []llm.LLMMessage, options ...llm.LLMCallOption) (llm.LLMStream, error) {
+	response, err := s.Call(ctx, msgs, options...)
+	if err != nil {
+		return nil, err
+	}
+	return llm.NewLLMMessageStream(response), nil
+}
+
+func toolCallMessage(toolCalls ...llm.LLMToolCall) llm.LLMMessage {
+	return llm.LLMMessage{
+		Type:      llm.LLMMessageTypeAssistant,
+		ToolCalls: toolCalls,
+	}
+}
+
+func finalMessage(content string) llm.LLMMessage {
+	return llm.LLMMessage{
+		Type:    llm.LLMMessageTypeAssistant,
+		Content: content,
+		End:     true,
+	}
+}
+
 func TestSumAgent(t *testing.T) {
 	// given
 	apiKey := os.Getenv("OPENAI_API_KEY")
@@ -100,3 +146,847 @@ func TestSumAgent(t *testing.T) {
 	t.Logf("Test passed! Agent successfully calculated: %d + %d = %d",
 		input.Num1, input.Num2, result.Data.Sum)
 }
+
+func TestAgentFinishesWhenToolLimitIsExhausted(t *testing.T) {
+	// given
+	args := map[string]any{"num1": 3.0, "num2": 5.0}
+	scripted := newScriptedLLM(
+		toolCallMessage(llm.NewLLMToolCall("call_1", "add", args)),
+		finalMessage(`{"total":8}`),
+		finalMessage(`{"sum":8}`),
+	)
+	calculatorAgent, err := agent.NewAgent(
+		agent.WithLLM[Result](scripted),
+		agent.WithBehavior[Result]("You are a calculator agent."),
+		agent.WithTool[Result]("add", createAddTool()),
+		agent.WithToolLimit[Result]("add", 1),
+		agent.WithOutputSchema(&Result{}),
+		agent.WithOutputRepairAttempts[Result](1),
+	)
+	require.NoError(t, err)
+
+	// when
+	result, err := calculatorAgent.Run(context.Background(), AddNumbers{Num1: 3, Num2: 5})
+
+	// then
+	require.ErrorIs(t, err, agent.ErrLimitReached)
+	require.NotNil(t, result)
+	require.NotNil(t, result.Data)
+	assert.Equal(t, 8, result.Data.Sum)
+	assert.Equal(t, 1, result.Repairs)
+
+	// the final turn follows the call that used up the limit
+	require.Len(t, scripted.calls, 3)
+	assert.False(t, scripted.calls[0].DisableTools)
+	assert.True(t, scripted.calls[1].DisableTools)
+	assert.True(t, scripted.calls[2].DisableTools)
+}
+
+func TestAgentFinishesWhenToolLimitIsZero(t *testing.T) {
+	// given
+	args := map[string]any{"num1": 3.0, "num2": 5.0}
+	scripted := newScriptedLLM(
+		toolCallMessage(llm.NewLLMToolCall("call_1", "add", args)),
+		finalMessage(`{"sum":8}`),
+	)
+	calculatorAgent, err := agent.NewAgent(
+		agent.WithLLM[Result](scripted),
+		agent.WithBehavior[Result]("You are a calculator agent."),
+		agent.WithTool[Result]("add", createAddTool()),
+		agent.WithToolLimit[Result]("add", 0),
+		agent.WithOutputSchema(&Result{}),
+	)
+	require.NoError(t, err)
+
+	// when
+	result, err := calculatorAgent.Run(context.Background(), AddNumbers{Num1: 3, Num2: 5})
+
+	// then
+	require.ErrorIs(t, err, agent.ErrLimitReached)
+	require.NotNil(t, result.Data)
+	assert.Equal(t, 8, result.Data.Sum)
+
+	require.Len(t, scripted.calls, 2)
+	assert.True(t, scripted.calls[1].DisableTools)
+}
+
+func TestAgentStopsWhenRunBudgetIsExhausted(t *testing.T) {
+	// given
+	args := map[string]any{"num1": 3.0, "num2": 5.0}
+	toolCall := toolCallMessage(llm.NewLLMToolCall("call_1", "add", args))
+	toolCall.Usage = llm.LLMUsage{PromptTokens: 80, CompletionTokens: 20, TotalTokens: 100}
+	calculatorAgent, err := agent.NewAgent(
+		agent.WithLLM[Result](newScriptedLLM(toolCall, toolCall, toolCall, toolCall)),
+		agent.WithBehavior[Result]("You are a calculator agent."),
+		agent.WithTool[Result]("add", createAddTool()),
+		agent.WithOutputSchema(&Result{}),
+		agent.WithBudget[Result](agent.Budget{MaxIterations: 10, MaxTokens: 1000}),
+	)
+	require.NoError(t, err)
+
+	// when
+	result, err := calculatorAgent.Run(
+		context.Background(),
+		AddNumbers{Num1: 3, Num2: 5},
+		agent.WithRunBudget(agent.Budget{MaxIterations: 2}),
+	)
+
+	// then
+	assert.Nil(t, result)
+	require.ErrorIs(t, err, agent.ErrMaxIterationsReached)
+
+	var budgetErr *agent.BudgetExceededError
+	require.ErrorAs(t, err, &budgetErr)
+	assert.Equal(t, 2, budgetErr.State.Iterations)
+	assert.Equal(t, int64(200), budgetErr.State.Usage.TotalTokens)
+	assert.Equal(t, 2, budgetErr.State.ToolUsage["add"])
+	assert.Len(t, budgetErr.State.Messages, 4)
+}
+
+func TestAgentReportsToolErrorsToModel(t *testing.T) {
+	// given
+	scripted := newScriptedLLM(
+		toolCallMessage(
+			llm.NewLLMToolCall("call_1", "multiply", map[string]any{"num1": 3.0, "num2": 5.0}),
+			llm.NewLLMToolCall("call_2", "add", map[string]any{"num1": "three", "num2": 5.0}),
+		),
+		toolCallMessage(llm.NewLLMToolCall("call_3", "add", map[string]any{"num1": 3.0, "num2": 5.0})),
+		finalMessage(`{"sum":8}`),
+	)
+	calculatorAgent, err := agent.NewAgent(
+		agent.WithLLM[Result](scripted),
+		agent.WithBehavior[Result]("You are a calculator agent."),
+		agent.WithTool[Result]("add", createAddTool()),
+		agent.WithOutputSchema(&Result{}),
+		agent.WithToolErrorRecovery[Result](2),
+	)
+	require.NoError(t, err)
+
+	// when
+	result, err := calculatorAgent.Run(context.Background(), AddNumbers{Num1: 3, Num2: 5})
+
+	// then
+	require.NoError(t, err)
+	assert.Equal(t, 8, result.Data.Sum)
+
+	failed := result.Messages[2].ToolResults
+	require.Len(t, failed, 2)
+	assert.Contains(t, failed[0].(llm.LLMToolErrorResult).Error, agent.ErrToolNotFound.Error())
+	assert.Contains(t, failed[1].(llm.LLMToolErrorResult).Error, llm.ErrInvalidArguments.Error())
+	assert.IsType(t, AddToolResult{}, result.Messages[3].ToolResults[0])
+}
+
+func TestAgentFailsAfterTooManyConsecutiveToolErrors(t *testing.T) {
+	// given
+	badArgs := map[string]any{"num1": "three", "num2": 5.0}
+	calculatorAgent, err := agent.NewAgent(
+		agent.WithLLM[Result](newScriptedLLM(
+			toolCallMessage(llm.NewLLMToolCall("call_1", "add", badArgs)),
+			toolCallMessage(llm.NewLLMToolCall("call_2", "add", badArgs)),
+		)),
+		agent.WithBehavior[Result]("You are a calculator agent."),
+		agent.WithTool[Result]("add", createAddTool()),
+		agent.WithOutputSchema(&Result{}),
+		agent.WithToolErrorRecovery[Result](1),
+	)
+	require.NoError(t, err)
+
+	// when
+	_, err = calculatorAgent.Run(context.Background(), AddNumbers{Num1: 3, Num2: 5})
+
+	// then
+	require.ErrorIs(t, err, agent.ErrTooManyToolFailures)
+	assert.ErrorIs(t, err, llm.ErrInvalidArguments)
+}
+
+func TestAgentCountsConsecutiveToolErrorsInCallOrder(t *testing.T) {
+	// given
+	badArgs := map[string]any{"num1": "three", "num2": 5.0}
+	calculatorAgent, err := agent.NewAgent(
+		agent.WithLLM[Result](newScriptedLLM(
+			toolCallMessage(
+				llm.NewLLMToolCall("call_1", "add", badArgs),
+				llm.NewLLMToolCall("call_2", "add", map[string]any{"num1": 3.0, "num2": 5.0}),
+				llm.NewLLMToolCall("call_3", "add", badArgs),
+			),
+			finalMessage(`{"sum":8}`),
+		)),
+		agent.WithBehavior[Result]("You are a calculator agent."),
+		agent.WithTool[Result]("add", createAddTool()),
+		agent.WithOutputSchema(&Result{}),
+		agent.WithToolErrorRecovery[Result](1),
+	)
+	require.NoError(t, err)
+
+	// when
+	result, err := calculatorAgent.Run(context.Background(), AddNumbers{Num1: 3, Num2: 5})
+
+	// then
+	require.NoError(t, err)
+	assert.Equal(t, 8, result.Data.Sum)
+}
+
+func TestAgentFailsOnUnknownToolWithoutRecovery(t *testing.T) {
+	// given
+	calculatorAgent, err := agent.NewAgent(
+		agent.WithLLM[Result](newScriptedLLM(
+			toolCallMessage(llm.NewLLMToolCall("call_1", "multiply", map[string]any{"num1": 3.0, "num2": 5.0})),
+		)),
+		agent.WithBehavior[Result]("You are a calculator agent."),
+		agent.WithTool[Result]("add", createAddTool()),
+		agent.WithOutputSchema(&Result{}),
+	)
+	require.NoError(t, err)
+
+	// when
+	_, err = calculatorAgent.Run(context.Background(), AddNumbers{Num1: 3, Num2: 5})
+
+	// then
+	require.ErrorIs(t, err, agent.ErrToolError)
+	assert.ErrorIs(t, err, agent.ErrToolNotFound)
+}
+
+func TestAgentRepairsInvalidOutput(t *testing.T) {
+	// given
+	calculatorAgent, err := agent.NewAgent(
+		agent.WithLLM[Result](newScriptedLLM(
+			finalMessage(`{"total":8}`),
+			finalMessage(`{"sum":8}`),
+		)),
+		agent.WithBehavior[Result]("You are a calculator agent."),
+		agent.WithOutputSchema(&Result{}),
+		agent.WithOutputRepairAttempts[Result](1),
+	)
+	require.NoError(t, err)
+
+	// when
+	result, err := calculatorAgent.Run(context.Background(), AddNumbers{Num1: 3, Num2: 5})
+
+	// then
+	require.NoError(t, err)
+	assert.Equal(t, 8, result.Data.Sum)
+	assert.Equal(t, 1, result.Repairs)
+
+	repairRequest := result.Messages[3]
+	assert.Equal(t, llm.LLMMessageTypeUser, repairRequest.Type)
+	assert.Contains(t, repairRequest.Content, "sum is required")
+}
+
+func TestAgentPassesResponseSchemaToLLM(t *testing.T) {
+	// given
+	scripted := newScriptedLLM(finalMessage(`{"sum":8}`))
+	calculatorAgent, err := agent.NewAgent(
+		agent.WithLLM[Result](scripted),
+		agent.WithBehavior[Result]("You are a calculator agent."),
+		agent.WithOutputSchema(&Result{}),
+	)
+	require.NoError(t, err)
+
+	// when
+	_, err = calculatorAgent.Run(context.Background(), AddNumbers{Num1: 3, Num2: 5})
+
+	// then
+	require.NoError(t, err)
+	require.Len(t, scripted.calls, 1)
+	schema := scripted.calls[0].ResponseSchema
+	assert.Equal(t, "object", schema["type"])
+	assert.NotContains(t, schema, "$schema")
+	assert.Equal(t, []any{"sum"}, schema["required"])
+	assert.Equal(t, false, schema["additionalProperties"])
+	assert.True(t, scripted.calls[0].ResponseSchemaStrict)
+}
+
+type Report struct {
+	Title string   `json:"title"`
+	Notes []string `json:"notes,omitempty"`
+}
+
+func TestAgentPassesResponseSchemaOnTurns(t *testing.T) {
+	tests := []struct {
+		name              string
+		structuredOutputs bool
+		wantSchema        []bool
+	}{
+		{name: "structured outputs", structuredOutputs: true, wantSchema: []bool{true, true, true}},
+		{name: "final turns only", wantSchema: []bool{false, false, true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// given
+			scripted := newScriptedLLM(
+				toolCallMessage(llm.NewLLMToolCall("call_1", "add", map[string]any{"num1": 3.0, "num2": 5.0})),
+				finalMessage(`{"notes":[]}`),
+				finalMessage(`{"title":"sum"}`),
+			)
+			reportAgent, err := agent.NewAgent(
+				agent.WithLLM[Report](scripted),
+				agent.WithLLMConfig[Report](llm.LLMConfig{StructuredOutputs: tt.structuredOutputs}),
+				agent.WithBehavior[Report]("You are a reporting agent."),
+				agent.WithTool[Report]("add", createAddTool()),
+				agent.WithOutputSchema(&Report{}),
+				agent.WithOutputRepairAttempts[Report](1),
+			)
+			require.NoError(t, err)
+
+			// when
+			result, err := reportAgent.Run(context.Background(), AddNumbers{Num1: 3, Num2: 5})
+
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, "sum", result.Data.Title)
+
+			require.Len(t, scripted.calls, len(tt.wantSchema))
+			for i, call := range scripted.calls {
+				assert.Equal(t, tt.wantSchema[i], call.ResponseSchema != nil, "call %d", i)
+				// the optional notes field cannot be enforced in strict mode
+				assert.False(t, call.ResponseSchemaStrict)
+			}
+		})
+	}
+}
+
+func TestAgentRunsToolCallsInParallel(t *testing.T) {
+	// given
+	var inFlight, maxInFlight atomic.Int32
+	slowAdd := createAddTool()
+	add := slowAdd.Call
+	slowAdd.Call = func(ctx context.Context, id string, args map[string]any) (llm.LLMToolResult, error) {
+		current := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			observed := maxInFlight.Load()
+			if current <= observed || maxInFlight.CompareAndSwap(observed, current) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		return add(ctx, id, args)
+	}
+
+	var toolCalls []llm.LLMToolCall
+	for i := 1; i <= 4; i++ {
+		toolCalls = append(toolCalls, llm.NewLLMToolCall(
+			fmt.Sprintf("call_%d", i),
+			"add",
+			map[string]any{"num1": float64(i), "num2": 1.0},
+		))
+	}
+	calculatorAgent, err := agent.NewAgent(
+		agent.WithLLM[Result](newScriptedLLM(toolCallMessage(toolCalls...), finalMessage(`{"sum":8}`))),
+		agent.WithBehavior[Result]("You are a calculator agent."),
+		agent.WithTool[Result]("add", slowAdd),
+		agent.WithOutputSchema(&Result{}),
+		agent.WithParallelToolCalls[Result](2),
+	)
+	require.NoError(t, err)
+
+	// when
+	result, err := calculatorAgent.Run(context.Background(), AddNumbers{Num1: 3, Num2: 5})
+
+	// then
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), maxInFlight.Load())
+
+	toolResults := result.Messages[2].ToolResults
+	require.Len(t, toolResults, 4)
+	for i, toolResult := range toolResults {
+		assert.Equal(t, fmt.Sprintf("call_%d", i+1), toolResult.GetID())
+		assert.Equal(t, float64(i+2), toolResult.(AddToolResult).Sum)
+	}
+}
+
+func TestAgentReportsToolTimeoutsAndPanics(t *testing.T) {
+	// given
+	slowTool := llm.NewLLMTool(
+		llm.WithLLMToolName("slow"),
+		llm.WithLLMToolDescription("Never finishes on time"),
+		llm.WithLLMToolTimeout(10*time.Millisecond),
+		llm.WithLLMToolCallContext(func(ctx context.Context, id string, args map[string]any) (AddToolResult, error) {
+			<-ctx.Done()
+			return AddToolResult{}, ctx.Err()
+		}),
+	)
+	panicTool := llm.NewLLMTool(
+		llm.WithLLMToolName("panic"),
+		llm.WithLLMToolDescription("Always panics"),
+		llm.WithLLMToolCall(func(id string, args map[string]any) (AddToolResult, error) {
+			panic("boom")
+		}),
+	)
+	calculatorAgent, err := agent.NewAgent(
+		agent.WithLLM[Result](newScriptedLLM(
+			toolCallMessage(
+				llm.NewLLMToolCall("call_1", "slow", nil),
+				llm.NewLLMToolCall("call_2", "panic", nil),
+			),
+			finalMessage(`{"sum":8}`),
+		)),
+		agent.WithBehavior[Result]("You are a calculator agent."),
+		agent.WithTool[Result]("slow", slowTool),
+		agent.WithTool[Result]("panic", panicTool),
+		agent.WithOutputSchema(&Result{}),
+		agent.WithToolErrorRecovery[Result](0),
+	)
+	require.NoError(t, err)
+
+	// when
+	result, err := calculatorAgent.Run(context.Background(), AddNumbers{Num1: 3, Num2: 5})
+
+	// then
+	require.NoError(t, err)
+	failed := result.Messages[2].ToolResults
+	require.Len(t, failed, 2)
+	assert.Contains(t, failed[0].(llm.LLMToolErrorResult).Error, context.DeadlineExceeded.Error())
+	assert.Contains(t, failed[1].(llm.LLMToolErrorResult).Error, agent.ErrToolPanic.Error())
+	assert.Contains(t, failed[1].(llm.LLMToolErrorResult).Error, "boom")
+}
+
+func TestAgentReportsInvalidToolArgumentsToModel(t *testing.T) {
+	// given
+	scripted := newScriptedLLM(
+		toolCallMessage(
+			llm.NewRawLLMToolCall("call_1", "add", `{"num1": 3, "num2":`),
+			llm.NewLLMToolCall("call_2", "add", map[string]any{"num1": "three"}),
+		),
+		finalMessage(`{"sum":8}`),
+	)
+	calculatorAgent, err := agent.NewAgent(
+		agent.WithLLM[Result](scripted),
+		agent.WithBehavior[Result]("You are a calculator agent."),
+		agent.WithTool[Result]("add", createAddTool()),
+		agent.WithOutputSchema(&Result{}),
+	)
+	require.NoError(t, err)
+
+	// when
+	result, err := calculatorAgent.Run(context.Background(), AddNumbers{Num1: 3, Num2: 5})
+
+	// then
+	require.NoError(t, err)
+	failed := result.Messages[2].ToolResults
+	require.Len(t, failed, 2)
+
+	malformed := failed[0].(llm.LLMToolErrorResult).Error
+	assert.Contains(t, malformed, llm.ErrInvalidArguments.Error())
+	assert.Contains(t, malformed, "malformed JSON")
+
+	invalid := failed[1].(llm.LLMToolErrorResult).Error
+	assert.Contains(t, invalid, "num1: Invalid type")
+	assert.Contains(t, invalid, "num2 is required")
+}
+
+type recordingObserver struct {
+	agent.BaseAgentObserver
+	events []string
+}
+
+func (r *recordingObserver) OnRunStart(ctx context.Context, state *agent.AgentState) {
+	r.events = append(r.events, "run_start")
+}
+
+func (r *recordingObserver) OnLLMCallStart(ctx context.Context, msgs []llm.LLMMessage) {
+	r.events = append(r.events, "llm_call_start")
+}
+
+func (r *recordingObserver) OnLLMCallEnd(ctx context.Context, msg llm.LLMMessage, err error) {
+	r.events = append(r.events, "llm_call_end")
+}
+
+func (r *recordingObserver) OnToolCallStart(ctx context.Context, toolCall llm.LLMToolCall) {
+	r.events = append(r.events, "tool_call_start:"+toolCall.ToolName)
+}
+
+func (r *recordingObserver) OnToolCallEnd(ctx context.Context, toolCall llm.LLMToolCall, result llm.LLMToolResult, err error) {
+	r.events = append(r.events, "tool_call_end:"+toolCall.ToolName)
+}
+
+func (r *recordingObserver) OnSystemPromptRendered(ctx context.Context, prompt string) {
+	r.events = append(r.events, "system_prompt")
+}
+
+func (r *recordingObserver) OnOutputValidated(ctx context.Context, content string, err error) {
+	r.events = append(r.events, fmt.Sprintf("output_validated:%t", err == nil))
+}
+
+func (r *recordingObserver) OnRunFinish(ctx context.Context, state *agent.AgentState, err error) {
+	r.events = append(r.events, fmt.Sprintf("run_finish:%t", err == nil))
+}
+
+func TestAgentNotifiesObservers(t *testing.T) {
+	// given
+	observer := &recordingObserver{}
+	calculatorAgent, err := agent.NewAgent(
+		agent.WithLLM[Result](newScriptedLLM(
+			toolCallMessage(llm.NewLLMToolCall("call_1", "add", map[string]any{"num1": 3.0, "num2": 5.0})),
+			finalMessage(`{"sum":8}`),
+		)),
+		agent.WithBehavior[Result]("You are a calculator agent."),
+		agent.WithTool[Result]("add", createAddTool()),
+		agent.WithOutputSchema(&Result{}),
+		agent.WithObserver[Result](observer),
+	)
+	require.NoError(t, err)
+
+	// when
+	_, err = calculatorAgent.Run(context.Background(), AddNumbers{Num1: 3, Num2: 5})
+
+	// then
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"run_start",
+		"system_prompt",
+		"llm_call_start",
+		"llm_call_end",
+		"tool_call_start:add",
+		"tool_call_end:add",
+		"system_prompt",
+		"llm_call_start",
+		"llm_call_end",
+		"output_validated:true",
+		"run_finish:true",
+	}, observer.events)
+}
+
+func TestAgentRunStreamEmitsProgressEvents(t *testing.T) {
+	// given
+	toolCall := toolCallMessage(llm.NewLLMToolCall("call_1", "add", map[string]any{"num1": 3.0, "num2": 5.0}))
+	toolCall.Usage = llm.LLMUsage{PromptTokens: 80, CompletionTokens: 20, TotalTokens: 100}
+	final := finalMessage(`{"sum":8}`)
+	final.Usage = llm.LLMUsage{PromptTokens: 100, CompletionTokens: 10, TotalTokens: 110}
+	calculatorAgent, err := agent.NewAgent(
+		agent.WithLLM[Result](newScriptedLLM(toolCall, final)),
+		agent.WithBehavior[Result]("You are a calculator agent."),
+		agent.WithTool[Result]("add", createAddTool()),
+		agent.WithOutputSchema(&Result{}),
+	)
+	require.NoError(t, err)
+
+	// when
+	var events []agent.AgentEvent[Result]
+	for event := range calculatorAgent.RunStream(context.Background(), AddNumbers{Num1: 3, Num2: 5}) {
+		events = append(events, event)
+	}
+
+	// then
+	var types []agent.AgentEventType
+	for _, event := range events {
+		types = append(types, event.Type)
+	}
+	assert.Equal(t, []agent.AgentEventType{
+		agent.AgentEventTypeUsage,
+		agent.AgentEventTypeToolCallStarted,
+		agent.AgentEventTypeToolCallFinished,
+		agent.AgentEventTypeDelta,
+		agent.AgentEventTypeUsage,
+		agent.AgentEventTypeResult,
+	}, types)
+
+	assert.Equal(t, "add", events[1].ToolCall.ToolName)
+	assert.Equal(t, float64(8), events[2].ToolResult.(AddToolResult).Sum)
+	assert.Equal(t, `{"sum":8}`, events[3].Delta)
+	assert.Equal(t, int64(210), events[4].Usage.TotalTokens)
+	require.NotNil(t, events[5].Result)
+	assert.Equal(t, 8, events[5].Result.Data.Sum)
+}
+
+func TestAgentResumesFromCheckpoint(t *testing.T) {
+	// given
+	var toolCalls atomic.Int32
+	countingAdd := createAddTool()
+	add := countingAdd.Call
+	countingAdd.Call = func(ctx context.Context, id string, args map[string]any) (llm.LLMToolResult, error) {
+		toolCalls.Add(1)
+		return add(ctx, id, args)
+	}
+
+	store, err := agent.NewFileCheckpointStore(t.TempDir())
+	require.NoError(t, err)
+
+	newAgent := func(scripted *scriptedLLM) *agent.Agent[Result] {
+		calculatorAgent, err := agent.NewAgent(
+			agent.WithLLM[Result](scripted),
+			agent.WithBehavior[Result]("You are a calculator agent."),
+			agent.WithTool[Result]("add", countingAdd),
+			agent.WithOutputSchema(&Result{}),
+			agent.WithCheckpointStore[Result](store),
+		)
+		require.NoError(t, err)
+		return calculatorAgent
+	}
+
+	// the run fails on the LLM call after the tool call
+	interrupted := newAgent(newScriptedLLM(
+		toolCallMessage(llm.NewLLMToolCall("call_1", "add", map[string]any{"num1": 3.0, "num2": 5.0})),
+	))
+	_, err = interrupted.Run(context.Background(), AddNumbers{Num1: 3, Num2: 5}, agent.WithRunID("run-1"))
+	require.ErrorIs(t, err, agent.ErrLLMCall)
+
+	// when
+	result, err := newAgent(newScriptedLLM(finalMessage(`{"sum":8}`))).Resume(context.Background(), "run-1")
+
+	// then
+	require.NoError(t, err)
+	assert.Equal(t, "run-1", result.RunID)
+	assert.Equal(t, 8, result.Data.Sum)
+	assert.Equal(t, int32(1), toolCalls.Load())
+
+	toolResult := result.Messages[2].ToolResults[0]
+	assert.Equal(t, "call_1", toolResult.GetID())
+	assert.JSONEq(t, `{"id":"call_1","sum":8}`, string(toolResult.(llm.LLMRawToolResult).Raw))
+}
+
+func TestAgentResumeExecutesOnlyPendingToolCalls(t *testing.T) {
+	// given
+	var executed []string
+	recordingAdd := createAddTool()
+	add := recordingAdd.Call
+	recordingAdd.Call = func(ctx context.Context, id string, args map[string]any) (llm.LLMToolResult, error) {
+		executed = append(executed, id)
+		return add(ctx, id, args)
+	}
+
+	response := toolCallMessage(
+		llm.NewLLMToolCall("call_1", "add", map[string]any{"num1": 3.0, "num2": 5.0}),
+		llm.NewLLMToolCall("call_2", "add", map[string]any{"num1": 1.0, "num2": 2.0}),
+	)
+	response.ToolResults = []llm.LLMToolResult{
+		AddToolResult{BaseLLMToolResult: llm.BaseLLMToolResult{ID: "call_1"}, Sum: 8},
+	}
+	store := agent.NewMemoryCheckpointStore()
+	require.NoError(t, store.Save(context.Background(), &agent.AgentState{
+		RunID: "run-1",
+		Messages: []llm.LLMMessage{
+			llm.NewLLMMessage(llm.LLMMessageTypeSystem, "You are a calculator agent."),
+			llm.NewLLMMessage(llm.LLMMessageTypeUser, `{"num1":3,"num2":5}`),
+			response,
+		},
+		ToolUsage: map[string]int{"add": 1},
+	}))
+
+	calculatorAgent, err := agent.NewAgent(
+		agent.WithLLM[Result](newScriptedLLM(finalMessage(`{"sum":8}`))),
+		agent.WithBehavior[Result]("You are a calculator agent."),
+		agent.WithTool[Result]("add", recordingAdd),
+		agent.WithOutputSchema(&Result{}),
+		agent.WithCheckpointStore[Result](store),
+	)
+	require.NoError(t, err)
+
+	// when
+	result, err := calculatorAgent.Resume(context.Background(), "run-1")
+
+	// then
+	require.NoError(t, err)
+	assert.Equal(t, []string{"call_2"}, executed)
+	assert.Len(t, result.Messages[2].ToolResults, 2)
+
+	saved, err := store.Load(context.Background(), "run-1")
+	require.NoError(t, err)
+	assert.Equal(t, 2, saved.ToolUsage["add"])
+	assert.True(t, saved.Messages[len(saved.Messages)-1].End)
+}
+
+func TestAgentResumesFinalTurnAfterToolLimit(t *testing.T) {
+	// given
+	var toolCalls atomic.Int32
+	countingAdd := createAddTool()
+	add := countingAdd.Call
+	countingAdd.Call = func(ctx context.Context, id string, args map[string]any) (llm.LLMToolResult, error) {
+		toolCalls.Add(1)
+		return add(ctx, id, args)
+	}
+
+	store := agent.NewMemoryCheckpointStore()
+	newAgent := func(scripted *scriptedLLM) *agent.Agent[Result] {
+		calculatorAgent, err := agent.NewAgent(
+			agent.WithLLM[Result](scripted),
+			agent.WithBehavior[Result]("You are a calculator agent."),
+			agent.WithTool[Result]("add", countingAdd),
+			agent.WithToolLimit[Result]("add", 1),
+			agent.WithOutputSchema(&Result{}),
+			agent.WithCheckpointStore[Result](store),
+		)
+		require.NoError(t, err)
+		return calculatorAgent
+	}
+
+	// the run fails on the final turn after the limit is used up
+	interrupted := newAgent(newScriptedLLM(
+		toolCallMessage(llm.NewLLMToolCall("call_1", "add", map[string]any{"num1": 3.0, "num2": 5.0})),
+	))
+	_, err := interrupted.Run(context.Background(), AddNumbers{Num1: 3, Num2: 5}, agent.WithRunID("run-1"))
+	require.ErrorIs(t, err, agent.ErrLLMCall)
+
+	// when
+	resumed := newScriptedLLM(finalMessage(`{"sum":8}`))
+	result, err := newAgent(resumed).Resume(context.Background(), "run-1")
+
+	// then
+	require.ErrorIs(t, err, agent.ErrLimitReached)
+	assert.Equal(t, 8, result.Data.Sum)
+	assert.Equal(t, int32(1), toolCalls.Load())
+
+	require.Len(t, resumed.calls, 1)
+	assert.True(t, resumed.calls[0].DisableTools)
+	assert.NotNil(t, resumed.calls[0].ResponseSchema)
+}
+
+func TestAgentResumeFailsForUnknownRun(t *testing.T) {
+	// given
+	calculatorAgent, err := agent.NewAgent(
+		agent.WithLLM[Result](newScriptedLLM()),
+		agent.WithBehavior[Result]("You are a calculator agent."),
+		agent.WithOutputSchema(&Result{}),
+		agent.WithCheckpointStore[Result](agent.NewMemoryCheckpointStore()),
+	)
+	require.NoError(t, err)
+
+	// when
+	_, err = calculatorAgent.Resume(context.Background(), "missing")
+
+	// then
+	require.ErrorIs(t, err, agent.ErrCheckpointNotFound)
+}
+
+// messagesObserver records the messages of every LLM call.
+type messagesObserver struct {
+	agent.BaseAgentObserver
+	calls   [][]llm.LLMMessage
+	prompts []string
+}
+
+func (m *messagesObserver) OnLLMCallStart(ctx context.Context, msgs []llm.LLMMessage) {
+	m.calls = append(m.calls, append([]llm.LLMMessage(nil), msgs...))
+}
+
+func (m *messagesObserver) OnSystemPromptRendered(ctx context.Context, prompt string) {
+	m.prompts = append(m.prompts, prompt)
+}
+
+func TestSessionKeepsHistoryBetweenTurns(t *testing.T) {
+	// given
+	scripted := newScriptedLLM(
+		toolCallMessage(llm.NewLLMToolCall("call_1", "add", map[string]any{"num1": 3.0, "num2": 5.0})),
+		finalMessage(`{"sum":8}`),
+		finalMessage(`{"sum":10}`),
+	)
+	observer := &messagesObserver{}
+	calculatorAgent, err := agent.NewAgent(
+		agent.WithLLM[Result](scripted),
+		agent.WithBehavior[Result]("You are a calculator agent."),
+		agent.WithTool[Result]("add", createAddTool()),
+		agent.WithToolLimit[Result]("add", 2),
+		agent.WithOutputSchema(&Result{}),
+		agent.WithObserver[Result](observer),
+	)
+	require.NoError(t, err)
+	session := calculatorAgent.NewSession()
+
+	// when
+	first, err := session.Send(context.Background(), AddNumbers{Num1: 3, Num2: 5})
+	require.NoError(t, err)
+	second, err := session.Send(context.Background(), "Add 2 to the previous sum")
+
+	// then
+	require.NoError(t, err)
+	assert.Equal(t, 8, first.Data.Sum)
+	assert.Equal(t, 10, second.Data.Sum)
+	assert.Equal(t, first.RunID, second.RunID)
+
+	require.Len(t, observer.calls, 3)
+	followUp := observer.calls[2]
+	require.Len(t, followUp, 5)
+	assert.Equal(t, `{"sum":8}`, followUp[3].Content)
+	assert.Equal(t, `"Add 2 to the previous sum"`, followUp[4].Content)
+	assert.Contains(t, followUp[0].Content, "CURRENT TOOLS USAGE:\n{}\n")
+	assert.Len(t, session.Messages(), 6)
+
+	// the first turn renders its prompt and re-renders it after the tool call
+	require.Len(t, observer.prompts, 3)
+	assert.Equal(t, followUp[0].Content, observer.prompts[2])
+}
+
+func TestSessionKeepsTurnsFinishedOnToolLimit(t *testing.T) {
+	// given
+	scripted := newScriptedLLM(
+		toolCallMessage(llm.NewLLMToolCall("call_1", "add", map[string]any{"num1": 3.0, "num2": 5.0})),
+		finalMessage(`{"sum":8}`),
+		finalMessage(`{"sum":10}`),
+	)
+	observer := &messagesObserver{}
+	calculatorAgent, err := agent.NewAgent(
+		agent.WithLLM[Result](scripted),
+		agent.WithBehavior[Result]("You are a calculator agent."),
+		agent.WithTool[Result]("add", createAddTool()),
+		agent.WithToolLimit[Result]("add", 1),
+		agent.WithOutputSchema(&Result{}),
+		agent.WithObserver[Result](observer),
+	)
+	require.NoError(t, err)
+	session := calculatorAgent.NewSession()
+
+	// when
+	first, err := session.Send(context.Background(), AddNumbers{Num1: 3, Num2: 5})
+	require.ErrorIs(t, err, agent.ErrLimitReached)
+	second, err := session.Send(context.Background(), "Add 2 to the previous sum")
+
+	// then
+	require.NoError(t, err)
+	assert.Equal(t, 8, first.Data.Sum)
+	assert.Equal(t, 10, second.Data.Sum)
+
+	require.Len(t, observer.calls, 3)
+	followUp := observer.calls[2]
+	require.Len(t, followUp, 6)
+	assert.Equal(t, `{"sum":8}`, followUp[4].Content)
+	assert.Equal(t, `"Add 2 to the previous sum"`, followUp[5].Content)
+}
+
+func TestAgentAccountsUsagePerModelAndTurn(t *testing.T) {
+	// given
+	toolCall := toolCallMessage(llm.NewLLMToolCall("call_1", "add", map[string]any{"num1": 3.0, "num2": 5.0}))
+	toolCall.Model = "gpt-4.1-mini-2025-04-14"
+	toolCall.Usage = llm.LLMUsage{PromptTokens: 1000, CompletionTokens: 100, TotalTokens: 1100}
+	final := finalMessage(`{"sum":8}`)
+	final.Model = "gpt-4.1"
+	final.Usage = llm.LLMUsage{PromptTokens: 2000, CompletionTokens: 50, TotalTokens: 2050, CachedTokens: 1000, ReasoningTokens: 20}
+
+	calculatorAgent, err := agent.NewAgent(
+		agent.WithLLM[Result](newScriptedLLM(toolCall, final)),
+		agent.WithBehavior[Result]("You are a calculator agent."),
+		agent.WithTool[Result]("add", createAddTool()),
+		agent.WithOutputSchema(&Result{}),
+		agent.WithLLMPriceTable[Result](llm.LLMPriceTable{
+			"gpt-4.1":      {PromptPerMillion: 2, CachedPromptPerMillion: 0.5, CompletionPerMillion: 8},
+			"gpt-4.1-mini": {PromptPerMillion: 0.4, CompletionPerMillion: 1.6},
+		}),
+	)
+	require.NoError(t, err)
+
+	// when
+	result, err := calculatorAgent.Run(context.Background(), AddNumbers{Num1: 3, Num2: 5})
+
+	// then
+	require.NoError(t, err)
+	usage := result.Usage
+	assert.Equal(t, int64(3150), usage.Total.TotalTokens)
+	assert.Equal(t, int64(1000), usage.Total.CachedTokens)
+	assert.Equal(t, int64(20), usage.Total.ReasoningTokens)
+
+	miniCost := (1000*0.4 + 100*1.6) / 1_000_000
+	fullCost := (1000*2 + 1000*0.5 + 50*8) / 1_000_000
+	assert.InDelta(t, miniCost+fullCost, usage.Cost, 1e-12)
+	assert.InDelta(t, miniCost, usage.ByModel["gpt-4.1-mini-2025-04-14"].Cost, 1e-12)
+	assert.Equal(t, int64(2050), usage.ByModel["gpt-4.1"].Usage.TotalTokens)
+
+	require.Len(t, usage.Turns, 2)
+	assert.Equal(t, agent.TurnUsage{
+		Iteration: 1,
+		Model:     "gpt-4.1-mini-2025-04-14",
+		Usage:     toolCall.Usage,
+		Cost:      usage.Turns[0].Cost,
+		ToolCalls: []string{"add"},
+	}, usage.Turns[0])
+	assert.Empty(t, usage.Turns[1].ToolCalls)
+}
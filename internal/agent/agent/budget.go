@@ -0,0 +1,76 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrMaxIterationsReached = errors.New("max iterations reached")
+	ErrMaxTokensReached     = errors.New("max tokens reached")
+	ErrMaxDurationReached   = errors.New("max duration reached")
+	ErrMaxCostReached       = errors.New("max cost reached")
+)
+
+// Budget limits a single agent run. Zero values mean no limit.
+type Budget struct {
+	MaxIterations int           `json:"max_iterations"`
+	MaxTokens     int64         `json:"max_tokens"`
+	MaxDuration   time.Duration `json:"max_duration"`
+	MaxCost       float64       `json:"max_cost"`
+}
+
+// merge returns the budget with the non-zero limits of other taking precedence.
+func (b Budget) merge(other Budget) Budget {
+	if other.MaxIterations > 0 {
+		b.MaxIterations = other.MaxIterations
+	}
+	if other.MaxTokens > 0 {
+		b.MaxTokens = other.MaxTokens
+	}
+	if other.MaxDuration > 0 {
+		b.MaxDuration = other.MaxDuration
+	}
+	if other.MaxCost > 0 {
+		b.MaxCost = other.MaxCost
+	}
+	return b
+}
+
+// BudgetExceededError is returned when a run exhausts its budget. It carries
+// the state of the run at the moment the budget was exceeded.
+type BudgetExceededError struct {
+	Err   error
+	State *AgentState
+}
+
+func (e *BudgetExceededError) Error() string {
+	return fmt.Sprintf("%s: iterations = %d, tokens = %d", e.Err, e.State.Iterations, e.State.Usage.TotalTokens)
+}
+
+func (e *BudgetExceededError) Unwrap() error {
+	return e.Err
+}
+
+type RunOption func(*runOptions)
+
+type runOptions struct {
+	budget Budget
+	runID  string
+}
+
+func newRunOptions(options ...RunOption) *runOptions {
+	runOpts := &runOptions{}
+	for _, opt := range options {
+		opt(runOpts)
+	}
+	return runOpts
+}
+
+// WithRunBudget overrides the agent budget for a single run.
+func WithRunBudget(budget Budget) RunOption {
+	return func(o *runOptions) {
+		o.budget = budget
+	}
+}
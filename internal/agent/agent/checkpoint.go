@@ -0,0 +1,158 @@
+package agent
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+var (
+	ErrCheckpointNotFound = errors.New("checkpoint not found")
+	ErrCheckpoint         = errors.New("checkpoint error occurred")
+)
+
+// CheckpointStore persists the state of agent runs, so an interrupted run can
+// be continued with Agent.Resume.
+type CheckpointStore interface {
+	Save(ctx context.Context, state *AgentState) error
+	Load(ctx context.Context, runID string) (*AgentState, error)
+}
+
+// WithCheckpointStore saves the run state to the store after every step.
+func WithCheckpointStore[T any](store CheckpointStore) AgentOption[T] {
+	return func(a *Agent[T]) {
+		a.checkpoints = store
+	}
+}
+
+// WithRunID sets the ID the run is checkpointed under. A random ID is used by
+// default.
+func WithRunID(runID string) RunOption {
+	return func(o *runOptions) {
+		o.runID = runID
+	}
+}
+
+func newRunID() string {
+	id := make([]byte, 16)
+	_, _ = rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+func (a *Agent[T]) saveCheckpoint(ctx context.Context, state *AgentState) error {
+	if a.checkpoints == nil {
+		return nil
+	}
+	if err := a.checkpoints.Save(ctx, state); err != nil {
+		return fmt.Errorf("%w: run = %s: %w", ErrCheckpoint, state.RunID, err)
+	}
+	return nil
+}
+
+type memoryCheckpointStore struct {
+	mu     sync.RWMutex
+	states map[string][]byte
+}
+
+// NewMemoryCheckpointStore creates a store that keeps checkpoints in memory.
+// States are stored encoded, so later changes to a run do not leak into its
+// checkpoints.
+func NewMemoryCheckpointStore() CheckpointStore {
+	return &memoryCheckpointStore{
+		states: make(map[string][]byte),
+	}
+}
+
+func (s *memoryCheckpointStore) Save(ctx context.Context, state *AgentState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("failed to marshal state: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.states[state.RunID] = data
+	return nil
+}
+
+func (s *memoryCheckpointStore) Load(ctx context.Context, runID string) (*AgentState, error) {
+	s.mu.RLock()
+	data, ok := s.states[runID]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrCheckpointNotFound, runID)
+	}
+	return decodeState(data)
+}
+
+type fileCheckpointStore struct {
+	dir string
+}
+
+// NewFileCheckpointStore creates a store that keeps one JSON file per run in
+// dir.
+func NewFileCheckpointStore(dir string) (CheckpointStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create checkpoint directory: %w", err)
+	}
+	return &fileCheckpointStore{dir: dir}, nil
+}
+
+// Save writes the state to a temporary file first, so a crash while saving
+// keeps the previous checkpoint intact.
+func (s *fileCheckpointStore) Save(ctx context.Context, state *AgentState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("failed to marshal state: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(s.dir, state.RunID+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create checkpoint file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write checkpoint file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write checkpoint file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path(state.RunID)); err != nil {
+		return fmt.Errorf("failed to replace checkpoint file: %w", err)
+	}
+	return nil
+}
+
+func (s *fileCheckpointStore) Load(ctx context.Context, runID string) (*AgentState, error) {
+	data, err := os.ReadFile(s.path(runID))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrCheckpointNotFound, runID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoint file: %w", err)
+	}
+	return decodeState(data)
+}
+
+func (s *fileCheckpointStore) path(runID string) string {
+	return filepath.Join(s.dir, filepath.Base(runID)+".json")
+}
+
+func decodeState(data []byte) (*AgentState, error) {
+	var state AgentState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal state: %w", err)
+	}
+	if state.ToolUsage == nil {
+		state.ToolUsage = make(map[string]int)
+	}
+	return &state, nil
+}
@@ -0,0 +1,228 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"reddit-analyzer/internal/agent/llm"
+	"reddit-analyzer/internal/agent/llm/tokenizer"
+)
+
+var ErrContextCompaction = errors.New("context compaction error occurred")
+
+const summarizePrompt = `Summarize the conversation below between an agent, its user and its tools.
+Keep every fact, number, name and tool result the agent needs to finish its task. Reply with the summary only.`
+
+const summaryMessagePrompt = "Summary of the earlier conversation:\n%s"
+
+const omittedToolResult = "tool result removed to fit the context window"
+
+// ContextStrategy shrinks the messages of a run that no longer fit the model
+// context. Strategies must not modify the messages they are given, because
+// they are shared with checkpoints and sessions.
+type ContextStrategy interface {
+	Compact(ctx context.Context, msgs []llm.LLMMessage, target int, tok *tokenizer.Tokenizer) (Compaction, error)
+}
+
+// Compaction is the result of a context strategy.
+type Compaction struct {
+	Messages []llm.LLMMessage
+	// Responses are the LLM responses the strategy requested. Their usage is
+	// accounted in the run usage and budget.
+	Responses []llm.LLMMessage
+}
+
+type contextWindow struct {
+	size       int
+	threshold  float64
+	strategies []ContextStrategy
+}
+
+// WithContextWindow compacts the messages before an LLM call when their
+// estimated token count passes threshold of the model window, e.g. 0.8 of
+// 128000 tokens. The strategies are applied in order until the messages fit.
+// Tokens are counted with the tokenizer of the configured model unless
+// WithTokenizer is used.
+func WithContextWindow[T any](size int, threshold float64, strategies ...ContextStrategy) AgentOption[T] {
+	return func(a *Agent[T]) {
+		a.contextWindow = &contextWindow{
+			size:       size,
+			threshold:  threshold,
+			strategies: strategies,
+		}
+	}
+}
+
+// WithTokenizer sets the tokenizer the context window counts tokens with.
+func WithTokenizer[T any](tok *tokenizer.Tokenizer) AgentOption[T] {
+	return func(a *Agent[T]) {
+		a.tokenizer = tok
+	}
+}
+
+// compactContext applies the context strategies to the state messages when
+// they pass the threshold of the context window.
+func (a *Agent[T]) compactContext(ctx context.Context, state *AgentState) error {
+	if a.contextWindow == nil || len(a.contextWindow.strategies) == 0 {
+		return nil
+	}
+
+	tok := a.tokenizer
+	if tok == nil {
+		tok = tokenizer.ForModel(a.llmConfig.Model)
+	}
+
+	// tool schemas are sent with every request
+	tools := make([]llm.LLMTool, 0, len(a.tools))
+	for _, tool := range a.tools {
+		tools = append(tools, tool)
+	}
+	target := int(float64(a.contextWindow.size)*a.contextWindow.threshold) - tok.CountTools(tools)
+
+	for _, strategy := range a.contextWindow.strategies {
+		if tok.CountMessages(state.Messages) <= target {
+			return nil
+		}
+		compaction, err := strategy.Compact(ctx, state.Messages, target, tok)
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrContextCompaction, err)
+		}
+		state.Messages = compaction.Messages
+		for _, response := range compaction.Responses {
+			a.recordUsage(state, response)
+		}
+	}
+	return nil
+}
+
+type dropOldestToolResults struct {
+	keepRecent int
+}
+
+// NewDropOldestToolResultsStrategy replaces tool results with a placeholder,
+// oldest first, until the messages fit. Tool results of the last keepRecent
+// messages are kept.
+func NewDropOldestToolResultsStrategy(keepRecent int) ContextStrategy {
+	return &dropOldestToolResults{keepRecent: keepRecent}
+}
+
+func (s *dropOldestToolResults) Compact(ctx context.Context, msgs []llm.LLMMessage, target int, tok *tokenizer.Tokenizer) (Compaction, error) {
+	compacted := append([]llm.LLMMessage(nil), msgs...)
+	for i := 0; i < len(compacted)-s.keepRecent && tok.CountMessages(compacted) > target; i++ {
+		if len(compacted[i].ToolResults) == 0 {
+			continue
+		}
+		results := make([]llm.LLMToolResult, len(compacted[i].ToolResults))
+		for j, result := range compacted[i].ToolResults {
+			placeholder, err := newReplacedToolResult(result.GetID(), "omitted", omittedToolResult)
+			if err != nil {
+				return Compaction{}, err
+			}
+			results[j] = placeholder
+		}
+		compacted[i].ToolResults = results
+	}
+	return Compaction{Messages: compacted}, nil
+}
+
+type truncateToolResults struct {
+	maxTokens int
+}
+
+// NewTruncateToolResultsStrategy cuts every tool result larger than maxTokens
+// down to its first maxTokens.
+func NewTruncateToolResultsStrategy(maxTokens int) ContextStrategy {
+	return &truncateToolResults{maxTokens: maxTokens}
+}
+
+func (s *truncateToolResults) Compact(ctx context.Context, msgs []llm.LLMMessage, target int, tok *tokenizer.Tokenizer) (Compaction, error) {
+	compacted := append([]llm.LLMMessage(nil), msgs...)
+	for i, msg := range compacted {
+		var results []llm.LLMToolResult
+		for j, result := range msg.ToolResults {
+			content, err := json.Marshal(result)
+			if err != nil {
+				return Compaction{}, fmt.Errorf("failed to marshal tool result: %w", err)
+			}
+			if tok.Count(string(content)) <= s.maxTokens {
+				continue
+			}
+			if results == nil {
+				results = append([]llm.LLMToolResult(nil), msg.ToolResults...)
+			}
+			results[j], err = newReplacedToolResult(result.GetID(), "truncated", truncate(string(content), s.maxTokens, tok))
+			if err != nil {
+				return Compaction{}, err
+			}
+		}
+		if results != nil {
+			compacted[i].ToolResults = results
+		}
+	}
+	return Compaction{Messages: compacted}, nil
+}
+
+// truncate cuts the content to its longest prefix of at most maxTokens.
+func truncate(content string, maxTokens int, tok *tokenizer.Tokenizer) string {
+	runes := []rune(content)
+	// prefix counts grow with the prefix length, so the cut is searched
+	// in halves
+	cut := sort.Search(len(runes)+1, func(n int) bool {
+		return tok.Count(string(runes[:n])) > maxTokens
+	}) - 1
+	if cut >= len(runes) {
+		return content
+	}
+	return string(runes[:max(cut, 0)]) + "..."
+}
+
+func newReplacedToolResult(id string, key string, value string) (llm.LLMToolResult, error) {
+	raw, err := json.Marshal(map[string]string{"id": id, key: value})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal tool result: %w", err)
+	}
+	return llm.LLMRawToolResult{ID: id, Raw: raw}, nil
+}
+
+type summarizeOlderTurns struct {
+	llm        llm.LLM
+	keepRecent int
+}
+
+// NewSummarizeStrategy replaces the messages between the task input and the
+// last keepRecent messages with a summary written by the summarizer LLM. The
+// summarizer usage is accounted in the run usage and budget.
+func NewSummarizeStrategy(summarizer llm.LLM, keepRecent int) ContextStrategy {
+	return &summarizeOlderTurns{llm: summarizer, keepRecent: keepRecent}
+}
+
+func (s *summarizeOlderTurns) Compact(ctx context.Context, msgs []llm.LLMMessage, target int, tok *tokenizer.Tokenizer) (Compaction, error) {
+	// the system prompt and the task input are always kept
+	const head = 2
+	end := len(msgs) - s.keepRecent
+	if end-head < 2 {
+		return Compaction{Messages: msgs}, nil
+	}
+
+	transcript, err := json.Marshal(msgs[head:end])
+	if err != nil {
+		return Compaction{}, fmt.Errorf("failed to marshal messages: %w", err)
+	}
+	summary, err := s.llm.Call(ctx, []llm.LLMMessage{
+		llm.NewLLMMessage(llm.LLMMessageTypeSystem, summarizePrompt),
+		llm.NewLLMMessage(llm.LLMMessageTypeUser, string(transcript)),
+	}, llm.WithLLMCallToolsDisabled())
+	if err != nil {
+		return Compaction{}, fmt.Errorf("failed to summarize messages: %w", err)
+	}
+
+	compacted := make([]llm.LLMMessage, 0, head+1+s.keepRecent)
+	compacted = append(compacted, msgs[:head]...)
+	compacted = append(compacted, llm.NewLLMMessage(llm.LLMMessageTypeUser, fmt.Sprintf(summaryMessagePrompt, strings.TrimSpace(summary.Content))))
+	compacted = append(compacted, msgs[end:]...)
+	return Compaction{Messages: compacted, Responses: []llm.LLMMessage{summary}}, nil
+}
@@ -0,0 +1,172 @@
+package agent_test
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"reddit-analyzer/internal/agent/agent"
+	"reddit-analyzer/internal/agent/llm"
+	"reddit-analyzer/internal/agent/llm/tokenizer"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type commentsResult struct {
+	llm.BaseLLMToolResult
+	Comments string `json:"comments"`
+}
+
+func toolResultMessage(id string, comments string) llm.LLMMessage {
+	msg := toolCallMessage(llm.NewLLMToolCall(id, "comments", map[string]any{}))
+	msg.ToolResults = []llm.LLMToolResult{
+		commentsResult{BaseLLMToolResult: llm.BaseLLMToolResult{ID: id}, Comments: comments},
+	}
+	return msg
+}
+
+func rawToolResult(t *testing.T, result llm.LLMToolResult) map[string]string {
+	var decoded map[string]string
+	require.NoError(t, json.Unmarshal(result.(llm.LLMRawToolResult).Raw, &decoded))
+	return decoded
+}
+
+func conversation() []llm.LLMMessage {
+	return []llm.LLMMessage{
+		llm.NewLLMMessage(llm.LLMMessageTypeSystem, "system"),
+		llm.NewLLMMessage(llm.LLMMessageTypeUser, "task"),
+		toolResultMessage("call_1", strings.Repeat("a", 400)),
+		toolResultMessage("call_2", strings.Repeat("b", 400)),
+		toolResultMessage("call_3", strings.Repeat("c", 400)),
+	}
+}
+
+func TestDropOldestToolResultsStrategy(t *testing.T) {
+	// given
+	msgs := conversation()
+	strategy := agent.NewDropOldestToolResultsStrategy(1)
+	tok := tokenizer.New(tokenizer.Heuristic)
+	// dropping the oldest tool result is enough to save 50 tokens
+	target := tok.CountMessages(msgs) - 50
+
+	// when
+	compaction, err := strategy.Compact(context.Background(), msgs, target, tok)
+
+	// then
+	require.NoError(t, err)
+	compacted := compaction.Messages
+	require.Len(t, compacted, 5)
+	assert.Equal(t, map[string]string{"id": "call_1", "omitted": "tool result removed to fit the context window"}, rawToolResult(t, compacted[2].ToolResults[0]))
+	assert.Equal(t, msgs[3], compacted[3])
+	assert.Equal(t, msgs[4], compacted[4])
+	assert.IsType(t, commentsResult{}, msgs[2].ToolResults[0])
+}
+
+func TestTruncateToolResultsStrategy(t *testing.T) {
+	// given
+	msgs := conversation()[:3]
+	strategy := agent.NewTruncateToolResultsStrategy(10)
+
+	// when
+	compaction, err := strategy.Compact(context.Background(), msgs, 0, tokenizer.New(tokenizer.Heuristic))
+
+	// then
+	require.NoError(t, err)
+	truncated := rawToolResult(t, compaction.Messages[2].ToolResults[0])
+	assert.Equal(t, "call_1", truncated["id"])
+	assert.Equal(t, `{"id":"call_1","comments":"aaaaaaaaaaaaa...`, truncated["truncated"])
+	assert.IsType(t, commentsResult{}, msgs[2].ToolResults[0])
+}
+
+func TestSummarizeStrategy(t *testing.T) {
+	// given
+	msgs := conversation()
+	summarizer := newScriptedLLM(llm.NewLLMMessage(llm.LLMMessageTypeAssistant, "the comments were a and b"))
+	strategy := agent.NewSummarizeStrategy(summarizer, 1)
+
+	// when
+	compaction, err := strategy.Compact(context.Background(), msgs, 0, tokenizer.New(tokenizer.Heuristic))
+
+	// then
+	require.NoError(t, err)
+	compacted := compaction.Messages
+	require.Len(t, compacted, 4)
+	assert.Equal(t, msgs[:2], compacted[:2])
+	assert.Equal(t, llm.NewLLMMessage(llm.LLMMessageTypeUser, "Summary of the earlier conversation:\nthe comments were a and b"), compacted[2])
+	assert.Equal(t, msgs[4], compacted[3])
+	require.Len(t, summarizer.calls, 1)
+	assert.True(t, summarizer.calls[0].DisableTools)
+	assert.Equal(t, []llm.LLMMessage{llm.NewLLMMessage(llm.LLMMessageTypeAssistant, "the comments were a and b")}, compaction.Responses)
+}
+
+func TestAgentCompactsContextBeforeLLMCall(t *testing.T) {
+	// given
+	commentsTool := llm.NewLLMTool(
+		llm.WithLLMToolName("comments"),
+		llm.WithLLMToolDescription("Fetches the comments"),
+		llm.WithLLMToolCallContext(func(ctx context.Context, id string, args map[string]any) (llm.LLMToolResult, error) {
+			return commentsResult{BaseLLMToolResult: llm.BaseLLMToolResult{ID: id}, Comments: strings.Repeat("a", 4000)}, nil
+		}),
+	)
+	observer := &messagesObserver{}
+	analyzer, err := agent.NewAgent(
+		agent.WithLLM[Result](newScriptedLLM(
+			toolCallMessage(llm.NewLLMToolCall("call_1", "comments", map[string]any{})),
+			toolCallMessage(llm.NewLLMToolCall("call_2", "comments", map[string]any{})),
+			finalMessage(`{"sum":8}`),
+		)),
+		agent.WithBehavior[Result]("You are a comments analyzer."),
+		agent.WithTool[Result]("comments", commentsTool),
+		agent.WithOutputSchema(&Result{}),
+		agent.WithContextWindow[Result](4000, 0.5, agent.NewDropOldestToolResultsStrategy(1)),
+		agent.WithObserver[Result](observer),
+	)
+	require.NoError(t, err)
+
+	// when
+	_, err = analyzer.Run(context.Background(), AddNumbers{Num1: 3, Num2: 5})
+
+	// then
+	require.NoError(t, err)
+	require.Len(t, observer.calls, 3)
+	assert.IsType(t, commentsResult{}, observer.calls[1][2].ToolResults[0])
+
+	last := observer.calls[2]
+	assert.Equal(t, "call_1", rawToolResult(t, last[2].ToolResults[0])["id"])
+	assert.IsType(t, commentsResult{}, last[3].ToolResults[0])
+}
+
+func TestAgentAccountsSummarizerUsage(t *testing.T) {
+	// given
+	args := map[string]any{"num1": 3.0, "num2": 5.0}
+	toolCall := toolCallMessage(llm.NewLLMToolCall("call_1", "add", args))
+	toolCall.Usage = llm.LLMUsage{PromptTokens: 80, CompletionTokens: 20, TotalTokens: 100}
+	summary := llm.NewLLMMessage(llm.LLMMessageTypeAssistant, "the sums were 8")
+	summary.Model = "gpt-4.1-mini"
+	summary.Usage = llm.LLMUsage{PromptTokens: 1900, CompletionTokens: 100, TotalTokens: 2000}
+	calculatorAgent, err := agent.NewAgent(
+		agent.WithLLM[Result](newScriptedLLM(toolCall, toolCall, toolCall, finalMessage(`{"sum":8}`))),
+		agent.WithBehavior[Result]("You are a calculator agent."),
+		agent.WithTool[Result]("add", createAddTool()),
+		agent.WithOutputSchema(&Result{}),
+		agent.WithContextWindow[Result](100, 0.5, agent.NewSummarizeStrategy(newScriptedLLM(summary), 1)),
+		agent.WithBudget[Result](agent.Budget{MaxIterations: 10, MaxTokens: 1000}),
+	)
+	require.NoError(t, err)
+
+	// when
+	_, err = calculatorAgent.Run(context.Background(), AddNumbers{Num1: 3, Num2: 5})
+
+	// then
+	require.ErrorIs(t, err, agent.ErrMaxTokensReached)
+
+	var budgetErr *agent.BudgetExceededError
+	require.ErrorAs(t, err, &budgetErr)
+	assert.Equal(t, 3, budgetErr.State.Iterations)
+	assert.Equal(t, int64(2300), budgetErr.State.Usage.TotalTokens)
+	require.Len(t, budgetErr.State.Turns, 4)
+	assert.Equal(t, "gpt-4.1-mini", budgetErr.State.Turns[3].Model)
+	assert.Equal(t, summary.Usage, budgetErr.State.Turns[3].Usage)
+}
@@ -0,0 +1,54 @@
+package agent
+
+import (
+	"context"
+
+	"reddit-analyzer/internal/agent/llm"
+)
+
+// AgentObserver receives callbacks about what happens inside an agent run.
+// Tool callbacks are called concurrently when parallel tool calls are enabled.
+// Embed BaseAgentObserver to implement only the callbacks you need.
+type AgentObserver interface {
+	OnRunStart(ctx context.Context, state *AgentState)
+	OnLLMCallStart(ctx context.Context, msgs []llm.LLMMessage)
+	OnLLMCallEnd(ctx context.Context, msg llm.LLMMessage, err error)
+	OnToolCallStart(ctx context.Context, toolCall llm.LLMToolCall)
+	OnToolCallEnd(ctx context.Context, toolCall llm.LLMToolCall, result llm.LLMToolResult, err error)
+	OnSystemPromptRendered(ctx context.Context, prompt string)
+	OnOutputValidated(ctx context.Context, content string, err error)
+	OnRunFinish(ctx context.Context, state *AgentState, err error)
+}
+
+type BaseAgentObserver struct{}
+
+func (BaseAgentObserver) OnRunStart(ctx context.Context, state *AgentState) {}
+
+func (BaseAgentObserver) OnLLMCallStart(ctx context.Context, msgs []llm.LLMMessage) {}
+
+func (BaseAgentObserver) OnLLMCallEnd(ctx context.Context, msg llm.LLMMessage, err error) {}
+
+func (BaseAgentObserver) OnToolCallStart(ctx context.Context, toolCall llm.LLMToolCall) {}
+
+func (BaseAgentObserver) OnToolCallEnd(ctx context.Context, toolCall llm.LLMToolCall, result llm.LLMToolResult, err error) {
+}
+
+func (BaseAgentObserver) OnSystemPromptRendered(ctx context.Context, prompt string) {}
+
+func (BaseAgentObserver) OnOutputValidated(ctx context.Context, content string, err error) {}
+
+func (BaseAgentObserver) OnRunFinish(ctx context.Context, state *AgentState, err error) {}
+
+// WithObserver registers an observer of the agent runs. Observers are called
+// in the order they were registered.
+func WithObserver[T any](observer AgentObserver) AgentOption[T] {
+	return func(a *Agent[T]) {
+		a.observers = append(a.observers, observer)
+	}
+}
+
+func (a *Agent[T]) notify(callback func(observer AgentObserver)) {
+	for _, observer := range a.observers {
+		callback(observer)
+	}
+}
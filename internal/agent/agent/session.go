@@ -0,0 +1,89 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"reddit-analyzer/internal/agent/llm"
+)
+
+// Session is a conversation with an agent. Every turn continues the message
+// history of the previous turns, so follow-up inputs can refer to earlier
+// answers and tool results. Tool limits and budgets apply to each turn.
+type Session[T any] struct {
+	agent *Agent[T]
+
+	mu    sync.Mutex
+	state *AgentState
+}
+
+func (a *Agent[T]) NewSession() *Session[T] {
+	return &Session[T]{agent: a}
+}
+
+// Send runs a turn of the session with the input. A turn that failed without
+// a result is discarded from the history, so it can be retried. A turn that
+// returned data, e.g. with ErrLimitReached, is kept.
+func (s *Session[T]) Send(ctx context.Context, input any, options ...RunOption) (*AgentResult[T], error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	runOpts := newRunOptions(options...)
+	state, err := s.turnState(input, runOpts)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := s.agent.execute(ctx, state, runOpts, false)
+	if res != nil && res.Data != nil {
+		s.state = state
+	}
+	return res, err
+}
+
+// Messages returns the message history of the finished turns.
+func (s *Session[T]) Messages() []llm.LLMMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.state == nil {
+		return nil
+	}
+	return append([]llm.LLMMessage(nil), s.state.Messages...)
+}
+
+// turnState copies the history, appends the input and resets the per turn
+// counters. The first turn starts a new run like Agent.Run.
+func (s *Session[T]) turnState(input any, runOpts *runOptions) (*AgentState, error) {
+	if s.state == nil {
+		state, err := s.agent.createInitState(input)
+		if err != nil {
+			return nil, err
+		}
+		state.RunID = runOpts.runID
+		if state.RunID == "" {
+			state.RunID = newRunID()
+		}
+		return state, nil
+	}
+
+	inputJson, err := json.Marshal(input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal input: %w", err)
+	}
+
+	state := &AgentState{
+		RunID:     s.state.RunID,
+		Messages:  append([]llm.LLMMessage(nil), s.state.Messages...),
+		ToolUsage: make(map[string]int),
+	}
+	systemPrompt, err := s.agent.createSystemPrompt(state.ToolUsage)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create system prompt: %w", err)
+	}
+	state.Messages[0].Content = systemPrompt
+	state.AddMessage(llm.NewLLMMessage(llm.LLMMessageTypeUser, string(inputJson)))
+	return state, nil
+}
@@ -0,0 +1,91 @@
+package agent
+
+import (
+	"context"
+	"slices"
+
+	"reddit-analyzer/internal/agent/llm"
+)
+
+type AgentEventType string
+
+const (
+	AgentEventTypeDelta            AgentEventType = "delta"
+	AgentEventTypeToolCallStarted  AgentEventType = "tool_call_started"
+	AgentEventTypeToolCallFinished AgentEventType = "tool_call_finished"
+	AgentEventTypeUsage            AgentEventType = "usage"
+	AgentEventTypeResult           AgentEventType = "result"
+	AgentEventTypeError            AgentEventType = "error"
+)
+
+// AgentEvent is a progress update of a streamed run. Only the fields relevant
+// to the event type are set.
+type AgentEvent[T any] struct {
+	Type       AgentEventType    `json:"type"`
+	Delta      string            `json:"delta,omitempty"`
+	ToolCall   llm.LLMToolCall   `json:"tool_call,omitzero"`
+	ToolResult llm.LLMToolResult `json:"tool_result,omitempty"`
+	// Usage is the usage of the run so far.
+	Usage  llm.LLMUsage    `json:"usage,omitzero"`
+	Result *AgentResult[T] `json:"result,omitempty"`
+	Err    error           `json:"-"`
+}
+
+// RunStream runs the agent like Run and reports its progress on the returned
+// channel. The last event is the result or the error, after which the channel
+// is closed. Cancel ctx to stop the run when the events are no longer read.
+func (a *Agent[T]) RunStream(ctx context.Context, input any, options ...RunOption) <-chan AgentEvent[T] {
+	events := make(chan AgentEvent[T])
+	emitter := &streamEmitter[T]{ctx: ctx, events: events}
+
+	streamAgent := *a
+	streamAgent.observers = append(slices.Clone(a.observers), emitter)
+	streamAgent.onDelta = func(delta string) {
+		emitter.emit(AgentEvent[T]{Type: AgentEventTypeDelta, Delta: delta})
+	}
+
+	go func() {
+		defer close(events)
+		result, err := streamAgent.Run(ctx, input, options...)
+		if err != nil {
+			emitter.emit(AgentEvent[T]{Type: AgentEventTypeError, Result: result, Err: err})
+			return
+		}
+		emitter.emit(AgentEvent[T]{Type: AgentEventTypeResult, Result: result})
+	}()
+
+	return events
+}
+
+type streamEmitter[T any] struct {
+	BaseAgentObserver
+	ctx    context.Context
+	events chan<- AgentEvent[T]
+	usage  llm.LLMUsage
+}
+
+func (e *streamEmitter[T]) emit(event AgentEvent[T]) {
+	select {
+	case e.events <- event:
+	case <-e.ctx.Done():
+	}
+}
+
+func (e *streamEmitter[T]) OnLLMCallEnd(ctx context.Context, msg llm.LLMMessage, err error) {
+	if err != nil {
+		return
+	}
+	e.usage = e.usage.Add(msg.Usage)
+	e.emit(AgentEvent[T]{Type: AgentEventTypeUsage, Usage: e.usage})
+}
+
+func (e *streamEmitter[T]) OnToolCallStart(ctx context.Context, toolCall llm.LLMToolCall) {
+	e.emit(AgentEvent[T]{Type: AgentEventTypeToolCallStarted, ToolCall: toolCall})
+}
+
+func (e *streamEmitter[T]) OnToolCallEnd(ctx context.Context, toolCall llm.LLMToolCall, result llm.LLMToolResult, err error) {
+	if err != nil {
+		result = llm.NewLLMToolErrorResult(toolCall.ID, err)
+	}
+	e.emit(AgentEvent[T]{Type: AgentEventTypeToolCallFinished, ToolCall: toolCall, ToolResult: result})
+}
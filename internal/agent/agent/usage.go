@@ -0,0 +1,84 @@
+package agent
+
+import (
+	"reddit-analyzer/internal/agent/llm"
+)
+
+// TurnUsage is the usage of a single LLM call of a run together with the
+// tools the LLM called in its response.
+type TurnUsage struct {
+	Iteration int          `json:"iteration"`
+	Model     string       `json:"model"`
+	Usage     llm.LLMUsage `json:"usage"`
+	Cost      float64      `json:"cost"`
+	ToolCalls []string     `json:"tool_calls,omitempty"`
+}
+
+type ModelUsage struct {
+	Usage llm.LLMUsage `json:"usage"`
+	Cost  float64      `json:"cost"`
+}
+
+// AgentUsage is the token usage and the estimated USD cost of a run.
+type AgentUsage struct {
+	Total   llm.LLMUsage          `json:"total"`
+	Cost    float64               `json:"cost"`
+	ByModel map[string]ModelUsage `json:"by_model"`
+	Turns   []TurnUsage           `json:"turns"`
+}
+
+func newAgentUsage(turns []TurnUsage) AgentUsage {
+	usage := AgentUsage{
+		ByModel: make(map[string]ModelUsage),
+		Turns:   turns,
+	}
+	for _, turn := range turns {
+		usage.Total = usage.Total.Add(turn.Usage)
+		usage.Cost += turn.Cost
+
+		model := usage.ByModel[turn.Model]
+		model.Usage = model.Usage.Add(turn.Usage)
+		model.Cost += turn.Cost
+		usage.ByModel[turn.Model] = model
+	}
+	return usage
+}
+
+// WithLLMPriceTable sets the per model token prices used to estimate the cost
+// of runs. Models missing from the table use the price set by WithLLMPrice.
+func WithLLMPriceTable[T any](prices llm.LLMPriceTable) AgentOption[T] {
+	return func(a *Agent[T]) {
+		a.prices = prices
+	}
+}
+
+func (a *Agent[T]) cost(model string, usage llm.LLMUsage) float64 {
+	if price, ok := a.prices.Price(model); ok {
+		return price.Cost(usage)
+	}
+	return a.price.Cost(usage)
+}
+
+// recordUsage accounts the usage of the LLM response in the state.
+func (a *Agent[T]) recordUsage(state *AgentState, llmMessage llm.LLMMessage) {
+	model := llmMessage.Model
+	if model == "" {
+		model = a.llmConfig.Model
+	}
+
+	var toolCalls []string
+	for _, toolCall := range llmMessage.ToolCalls {
+		toolCalls = append(toolCalls, toolCall.ToolName)
+	}
+
+	turn := TurnUsage{
+		Iteration: state.Iterations,
+		Model:     model,
+		Usage:     llmMessage.Usage,
+		Cost:      a.cost(model, llmMessage.Usage),
+		ToolCalls: toolCalls,
+	}
+	state.Usage = state.Usage.Add(turn.Usage)
+	state.Cost += turn.Cost
+	state.Turns = append(state.Turns, turn)
+}
@@ -1,11 +1,15 @@
 package config
 
 import (
+	"encoding/json"
 	"log"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
+	"reddit-analyzer/internal/agent/llm"
+
 	"github.com/joho/godotenv"
 )
 
@@ -19,6 +23,17 @@ type OpenAIConfig struct {
 	MaxTokens   int
 	Temperature float64
 	Timeout     time.Duration
+	TopP        float64
+	// Seed is nil unless OPENAI_SEED is set.
+	Seed             *int64
+	Stop             []string
+	PresencePenalty  float64
+	FrequencyPenalty float64
+	// BaseURL targets an OpenAI compatible server instead of api.openai.com.
+	BaseURL               string
+	Organization          string
+	Headers               map[string]string
+	UnsupportedParameters []string
 }
 
 func NewConfig() *Config {
@@ -36,10 +51,42 @@ func NewConfig() *Config {
 			MaxTokens:   getEnvInt("OPENAI_MAX_TOKENS", 4096),
 			Temperature: getEnvFloat("OPENAI_TEMPERATURE", 0.7),
 			Timeout:     time.Duration(getEnvInt("OPENAI_TIMEOUT_SECONDS", 30)) * time.Second,
+
+			TopP:             getEnvFloat("OPENAI_TOP_P", 0),
+			Seed:             getEnvOptionalInt64("OPENAI_SEED"),
+			Stop:             getEnvJSONList("OPENAI_STOP"),
+			PresencePenalty:  getEnvFloat("OPENAI_PRESENCE_PENALTY", 0),
+			FrequencyPenalty: getEnvFloat("OPENAI_FREQUENCY_PENALTY", 0),
+
+			BaseURL:               os.Getenv("OPENAI_BASE_URL"),
+			Organization:          os.Getenv("OPENAI_ORGANIZATION"),
+			Headers:               getEnvMap("OPENAI_EXTRA_HEADERS"),
+			UnsupportedParameters: getEnvList("OPENAI_UNSUPPORTED_PARAMETERS"),
 		},
 	}
 }
 
+// LLMConfig returns the configuration of an OpenAI LLM.
+func (c *OpenAIConfig) LLMConfig() llm.LLMConfig {
+	return llm.LLMConfig{
+		Type:                  llm.LLMTypeOpenAI,
+		APIKey:                c.APIKey,
+		Model:                 c.Model,
+		Temperature:           c.Temperature,
+		MaxTokens:             int64(c.MaxTokens),
+		Timeout:               c.Timeout,
+		TopP:                  c.TopP,
+		Seed:                  c.Seed,
+		Stop:                  c.Stop,
+		PresencePenalty:       c.PresencePenalty,
+		FrequencyPenalty:      c.FrequencyPenalty,
+		BaseURL:               c.BaseURL,
+		Organization:          c.Organization,
+		Headers:               c.Headers,
+		UnsupportedParameters: c.UnsupportedParameters,
+	}
+}
+
 // getEnvStr returns the value of an environment variable or a default value if it's not set
 func getEnvStr(envVar string, defaultValue string) string {
 	v := os.Getenv(envVar)
@@ -66,6 +113,20 @@ func getEnvInt(envVar string, defaultValue int) int {
 	return i
 }
 
+// getEnvOptionalInt64 returns the value of an environment variable as an int64 or nil if it's not set
+func getEnvOptionalInt64(envVar string) *int64 {
+	v := os.Getenv(envVar)
+	if v == "" {
+		return nil
+	}
+
+	i, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		log.Fatalf("environment variable must be an integer: name = %s, value = %s", envVar, v)
+	}
+	return &i
+}
+
 // getEnvFloat returns the value of an environment variable as a float64 or a default value if it's not set
 func getEnvFloat(envVar string, defaultValue float64) float64 {
 	v := os.Getenv(envVar)
@@ -79,3 +140,52 @@ func getEnvFloat(envVar string, defaultValue float64) float64 {
 	}
 	return f
 }
+
+// getEnvList returns the comma separated values of an environment variable or nil if it's not set
+func getEnvList(envVar string) []string {
+	v := os.Getenv(envVar)
+	if v == "" {
+		return nil
+	}
+
+	var values []string
+	for _, value := range strings.Split(v, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// getEnvJSONList returns the JSON array of strings in an environment variable or nil if it's not set.
+// Unlike getEnvList it keeps whitespace values, e.g. ["\n\n", " END"]
+func getEnvJSONList(envVar string) []string {
+	v := os.Getenv(envVar)
+	if v == "" {
+		return nil
+	}
+
+	var values []string
+	if err := json.Unmarshal([]byte(v), &values); err != nil {
+		log.Fatalf("environment variable must be a JSON array of strings: name = %s, value = %s", envVar, v)
+	}
+	return values
+}
+
+// getEnvMap returns the comma separated key=value pairs of an environment variable or nil if it's not set
+func getEnvMap(envVar string) map[string]string {
+	values := getEnvList(envVar)
+	if len(values) == 0 {
+		return nil
+	}
+
+	m := make(map[string]string, len(values))
+	for _, value := range values {
+		key, val, ok := strings.Cut(value, "=")
+		if !ok {
+			log.Fatalf("environment variable must be a list of key=value pairs: name = %s, value = %s", envVar, value)
+		}
+		m[strings.TrimSpace(key)] = strings.TrimSpace(val)
+	}
+	return m
+}
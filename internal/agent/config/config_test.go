@@ -0,0 +1,77 @@
+package config_test
+
+import (
+	"testing"
+	"time"
+
+	"reddit-analyzer/internal/agent/config"
+	"reddit-analyzer/internal/agent/llm"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpenAIConfigFromEnv(t *testing.T) {
+	// given
+	t.Setenv("OPENAI_API_KEY", "test")
+	t.Setenv("OPENAI_MODEL", "gpt-4.1")
+	t.Setenv("OPENAI_MAX_TOKENS", "512")
+	t.Setenv("OPENAI_TEMPERATURE", "0.2")
+	t.Setenv("OPENAI_TIMEOUT_SECONDS", "10")
+	t.Setenv("OPENAI_TOP_P", "0.9")
+	t.Setenv("OPENAI_SEED", "42")
+	t.Setenv("OPENAI_STOP", `["\n\n"," END"]`)
+	t.Setenv("OPENAI_PRESENCE_PENALTY", "0.5")
+	t.Setenv("OPENAI_FREQUENCY_PENALTY", "-0.5")
+	t.Setenv("OPENAI_BASE_URL", "http://localhost:11434/v1")
+	t.Setenv("OPENAI_ORGANIZATION", "org-analytics")
+	t.Setenv("OPENAI_EXTRA_HEADERS", "X-Gateway-Team=analytics")
+	t.Setenv("OPENAI_UNSUPPORTED_PARAMETERS", "temperature, seed")
+
+	// when
+	cfg := config.NewConfig().OpenAI.LLMConfig()
+
+	// then
+	seed := int64(42)
+	assert.Equal(t, llm.LLMConfig{
+		Type:                  llm.LLMTypeOpenAI,
+		APIKey:                "test",
+		Model:                 "gpt-4.1",
+		Temperature:           0.2,
+		MaxTokens:             512,
+		Timeout:               10 * time.Second,
+		TopP:                  0.9,
+		Seed:                  &seed,
+		Stop:                  []string{"\n\n", " END"},
+		PresencePenalty:       0.5,
+		FrequencyPenalty:      -0.5,
+		BaseURL:               "http://localhost:11434/v1",
+		Organization:          "org-analytics",
+		Headers:               map[string]string{"X-Gateway-Team": "analytics"},
+		UnsupportedParameters: []string{"temperature", "seed"},
+	}, cfg)
+}
+
+func TestOpenAIConfigDefaults(t *testing.T) {
+	// given
+	t.Setenv("OPENAI_API_KEY", "test")
+	for _, name := range []string{
+		"OPENAI_MODEL", "OPENAI_MAX_TOKENS", "OPENAI_TEMPERATURE", "OPENAI_TIMEOUT_SECONDS",
+		"OPENAI_TOP_P", "OPENAI_SEED", "OPENAI_STOP", "OPENAI_PRESENCE_PENALTY", "OPENAI_FREQUENCY_PENALTY",
+		"OPENAI_BASE_URL", "OPENAI_ORGANIZATION", "OPENAI_EXTRA_HEADERS", "OPENAI_UNSUPPORTED_PARAMETERS",
+	} {
+		t.Setenv(name, "")
+	}
+
+	// when
+	cfg := config.NewConfig().OpenAI.LLMConfig()
+
+	// then
+	assert.Equal(t, llm.LLMConfig{
+		Type:        llm.LLMTypeOpenAI,
+		APIKey:      "test",
+		Model:       "gpt-4",
+		Temperature: 0.7,
+		MaxTokens:   4096,
+		Timeout:     30 * time.Second,
+	}, cfg)
+}
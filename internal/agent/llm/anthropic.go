@@ -0,0 +1,408 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	anthropicDefaultBaseURL   = "https://api.anthropic.com"
+	anthropicVersion          = "2023-06-01"
+	anthropicDefaultMaxTokens = 4096
+
+	anthropicStopReasonToolUse = "tool_use"
+)
+
+type anthropicLLM struct {
+	httpClient  *http.Client
+	baseURL     string
+	apiKey      string
+	model       string
+	temperature float64
+	maxTokens   int64
+	topP        float64
+	stop        []string
+	timeout     time.Duration
+	tools       []LLMTool
+	headers     map[string]string
+}
+
+type anthropicLLMOption func(a *anthropicLLM)
+
+func withAnthropicAPIKey(apiKey string) anthropicLLMOption {
+	return func(a *anthropicLLM) {
+		a.apiKey = apiKey
+	}
+}
+
+func withAnthropicModel(model string) anthropicLLMOption {
+	return func(a *anthropicLLM) {
+		a.model = model
+	}
+}
+
+func withAnthropicTemperature(temperature float64) anthropicLLMOption {
+	return func(a *anthropicLLM) {
+		a.temperature = temperature
+	}
+}
+
+func withAnthropicMaxTokens(maxTokens int64) anthropicLLMOption {
+	return func(a *anthropicLLM) {
+		if maxTokens > 0 {
+			a.maxTokens = maxTokens
+		}
+	}
+}
+
+func withAnthropicTopP(topP float64) anthropicLLMOption {
+	return func(a *anthropicLLM) {
+		a.topP = topP
+	}
+}
+
+func withAnthropicStop(stop []string) anthropicLLMOption {
+	return func(a *anthropicLLM) {
+		a.stop = stop
+	}
+}
+
+func withAnthropicTimeout(timeout time.Duration) anthropicLLMOption {
+	return func(a *anthropicLLM) {
+		a.timeout = timeout
+	}
+}
+
+func withAnthropicTools(tools []LLMTool) anthropicLLMOption {
+	return func(a *anthropicLLM) {
+		a.tools = tools
+	}
+}
+
+func withAnthropicBaseURL(baseURL string) anthropicLLMOption {
+	return func(a *anthropicLLM) {
+		if baseURL != "" {
+			a.baseURL = strings.TrimSuffix(baseURL, "/")
+		}
+	}
+}
+
+func withAnthropicHeaders(headers map[string]string) anthropicLLMOption {
+	return func(a *anthropicLLM) {
+		a.headers = headers
+	}
+}
+
+func init() {
+	RegisterLLM(LLMTypeAnthropic, func(cfg LLMConfig, tools []LLMTool) (LLM, error) {
+		return newAnthropicLLM(
+			withAnthropicAPIKey(cfg.APIKey),
+			withAnthropicModel(cfg.Model),
+			withAnthropicTemperature(cfg.Temperature),
+			withAnthropicMaxTokens(cfg.MaxTokens),
+			withAnthropicTopP(cfg.TopP),
+			withAnthropicStop(cfg.Stop),
+			withAnthropicTimeout(cfg.Timeout),
+			withAnthropicTools(tools),
+			withAnthropicBaseURL(cfg.BaseURL),
+			withAnthropicHeaders(cfg.Headers),
+		), nil
+	})
+}
+
+func newAnthropicLLM(options ...anthropicLLMOption) *anthropicLLM {
+	a := &anthropicLLM{
+		httpClient: http.DefaultClient,
+		baseURL:    anthropicDefaultBaseURL,
+		maxTokens:  anthropicDefaultMaxTokens,
+	}
+	for _, opt := range options {
+		opt(a)
+	}
+	return a
+}
+
+type anthropicRequest struct {
+	Model         string               `json:"model"`
+	MaxTokens     int64                `json:"max_tokens"`
+	System        string               `json:"system,omitempty"`
+	Messages      []anthropicMessage   `json:"messages"`
+	Tools         []anthropicTool      `json:"tools,omitempty"`
+	ToolChoice    *anthropicToolChoice `json:"tool_choice,omitempty"`
+	Temperature   *float64             `json:"temperature,omitempty"`
+	TopP          float64              `json:"top_p,omitempty"`
+	StopSequences []string             `json:"stop_sequences,omitempty"`
+}
+
+type anthropicMessage struct {
+	Role    string                  `json:"role"`
+	Content []anthropicContentBlock `json:"content"`
+}
+
+// anthropicContentBlock is the union of the text, tool_use and tool_result
+// content blocks.
+type anthropicContentBlock struct {
+	Type      string          `json:"type"`
+	Text      string          `json:"text,omitempty"`
+	ID        string          `json:"id,omitempty"`
+	Name      string          `json:"name,omitempty"`
+	Input     json.RawMessage `json:"input,omitempty"`
+	ToolUseID string          `json:"tool_use_id,omitempty"`
+	Content   string          `json:"content,omitempty"`
+	IsError   bool            `json:"is_error,omitempty"`
+}
+
+type anthropicTool struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	InputSchema map[string]any `json:"input_schema"`
+}
+
+type anthropicToolChoice struct {
+	Type string `json:"type"`
+}
+
+type anthropicResponse struct {
+	ID         string                  `json:"id"`
+	Model      string                  `json:"model"`
+	Content    []anthropicContentBlock `json:"content"`
+	StopReason string                  `json:"stop_reason"`
+	Usage      anthropicUsage          `json:"usage"`
+}
+
+type anthropicUsage struct {
+	InputTokens              int64 `json:"input_tokens"`
+	OutputTokens             int64 `json:"output_tokens"`
+	CacheCreationInputTokens int64 `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int64 `json:"cache_read_input_tokens"`
+}
+
+type anthropicErrorResponse struct {
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func (a *anthropicLLM) Call(ctx context.Context, msgs []LLMMessage, options ...LLMCallOption) (LLMMessage, error) {
+	request, err := a.createRequest(msgs, NewLLMCallOptions(options...))
+	if err != nil {
+		return LLMMessage{}, err
+	}
+
+	response, err := a.send(ctx, request)
+	if err != nil {
+		return LLMMessage{}, fmt.Errorf("Anthropic API call failed: %w", err)
+	}
+
+	return a.newLLMMessage(response), nil
+}
+
+// Stream replays the response of Call, the Messages API is not streamed yet.
+func (a *anthropicLLM) Stream(ctx context.Context, msgs []LLMMessage, options ...LLMCallOption) (LLMStream, error) {
+	msg, err := a.Call(ctx, msgs, options...)
+	if err != nil {
+		return nil, err
+	}
+	return NewLLMMessageStream(msg), nil
+}
+
+func (a *anthropicLLM) send(ctx context.Context, request anthropicRequest) (*anthropicResponse, error) {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	if a.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, a.timeout)
+		defer cancel()
+	}
+
+	httpRequest, err := http.NewRequestWithContext(ctx, http.MethodPost, a.baseURL+"/v1/messages", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	httpRequest.Header.Set("Content-Type", "application/json")
+	httpRequest.Header.Set("X-Api-Key", a.apiKey)
+	httpRequest.Header.Set("Anthropic-Version", anthropicVersion)
+	for key, value := range a.headers {
+		httpRequest.Header.Set(key, value)
+	}
+
+	httpResponse, err := a.httpClient.Do(httpRequest)
+	if err != nil {
+		return nil, err
+	}
+	defer httpResponse.Body.Close()
+
+	responseBody, err := io.ReadAll(httpResponse.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if httpResponse.StatusCode != http.StatusOK {
+		var errorResponse anthropicErrorResponse
+		if err := json.Unmarshal(responseBody, &errorResponse); err != nil || errorResponse.Error.Message == "" {
+			return nil, fmt.Errorf("status = %d, body = %s", httpResponse.StatusCode, responseBody)
+		}
+		return nil, fmt.Errorf("status = %d, type = %s, message = %s", httpResponse.StatusCode, errorResponse.Error.Type, errorResponse.Error.Message)
+	}
+
+	var response anthropicResponse
+	if err := json.Unmarshal(responseBody, &response); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+	return &response, nil
+}
+
+func (a *anthropicLLM) newLLMMessage(response *anthropicResponse) LLMMessage {
+	msg := LLMMessage{
+		Type:  LLMMessageTypeAssistant,
+		End:   response.StopReason != anthropicStopReasonToolUse,
+		Model: response.Model,
+		Usage: LLMUsage{
+			PromptTokens:     response.Usage.InputTokens + response.Usage.CacheCreationInputTokens + response.Usage.CacheReadInputTokens,
+			CompletionTokens: response.Usage.OutputTokens,
+			CachedTokens:     response.Usage.CacheReadInputTokens,
+		},
+	}
+	msg.Usage.TotalTokens = msg.Usage.PromptTokens + msg.Usage.CompletionTokens
+	if msg.Model == "" {
+		msg.Model = a.model
+	}
+
+	var content []string
+	for _, block := range response.Content {
+		switch block.Type {
+		case "text":
+			content = append(content, block.Text)
+		case "tool_use":
+			msg.ToolCalls = append(msg.ToolCalls, NewRawLLMToolCall(block.ID, block.Name, string(block.Input)))
+		}
+	}
+	msg.Content = strings.Join(content, "")
+	return msg
+}
+
+func (a *anthropicLLM) createRequest(msgs []LLMMessage, options LLMCallOptions) (anthropicRequest, error) {
+	messages, system, err := a.createMessages(msgs)
+	if err != nil {
+		return anthropicRequest{}, err
+	}
+
+	request := anthropicRequest{
+		Model:     a.model,
+		MaxTokens: a.maxTokens,
+		System:    system,
+		Messages:  messages,
+		// the Messages API has no seed and penalties
+		StopSequences: a.stop,
+	}
+	// newer models reject requests that set both temperature and top_p
+	if a.topP > 0 {
+		request.TopP = a.topP
+	} else {
+		request.Temperature = &a.temperature
+	}
+	for _, tool := range a.tools {
+		schema := tool.ParametersSchema
+		if schema == nil {
+			schema = map[string]any{"type": "object"}
+		}
+		request.Tools = append(request.Tools, anthropicTool{
+			Name:        tool.Name,
+			Description: tool.Description,
+			InputSchema: schema,
+		})
+	}
+	if options.DisableTools && len(request.Tools) > 0 {
+		request.ToolChoice = &anthropicToolChoice{Type: "none"}
+	}
+
+	return request, nil
+}
+
+// createMessages moves the system messages to the system prompt and sends the
+// tool results as tool_result blocks of the next user turn. Consecutive
+// messages of the same role are merged into a single turn.
+func (a *anthropicLLM) createMessages(msgs []LLMMessage) ([]anthropicMessage, string, error) {
+	var system []string
+	var messages []anthropicMessage
+	appendBlocks := func(role string, blocks ...anthropicContentBlock) {
+		if len(blocks) == 0 {
+			return
+		}
+		if n := len(messages); n > 0 && messages[n-1].Role == role {
+			messages[n-1].Content = append(messages[n-1].Content, blocks...)
+			return
+		}
+		messages = append(messages, anthropicMessage{Role: role, Content: blocks})
+	}
+
+	for _, msg := range msgs {
+		switch msg.Type {
+		case LLMMessageTypeSystem:
+			system = append(system, msg.Content)
+		case LLMMessageTypeUser:
+			appendBlocks("user", anthropicContentBlock{Type: "text", Text: msg.Content})
+		case LLMMessageTypeAssistant:
+			appendBlocks("assistant", a.createAssistantBlocks(msg)...)
+			results, err := a.createToolResultBlocks(msg)
+			if err != nil {
+				return nil, "", err
+			}
+			appendBlocks("user", results...)
+		}
+	}
+
+	return messages, strings.Join(system, "\n\n"), nil
+}
+
+func (a *anthropicLLM) createAssistantBlocks(msg LLMMessage) []anthropicContentBlock {
+	var blocks []anthropicContentBlock
+	if msg.Content != "" {
+		blocks = append(blocks, anthropicContentBlock{Type: "text", Text: msg.Content})
+	}
+	for _, toolCall := range msg.ToolCalls {
+		input := json.RawMessage(toolCall.ArgsJSON())
+		if _, err := toolCall.ParseArgs(); err != nil {
+			// tool_use input must be an object, malformed arguments are
+			// reported to the model by the tool result
+			input = json.RawMessage("{}")
+		}
+		blocks = append(blocks, anthropicContentBlock{
+			Type:  "tool_use",
+			ID:    toolCall.ID,
+			Name:  toolCall.ToolName,
+			Input: input,
+		})
+	}
+	return blocks
+}
+
+// createToolResultBlocks marks failed tool calls as errors, so the model
+// does not mistake the error message for a tool output.
+func (a *anthropicLLM) createToolResultBlocks(msg LLMMessage) ([]anthropicContentBlock, error) {
+	turns, err := toolResultTurns(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	blocks := make([]anthropicContentBlock, 0, len(turns))
+	for _, turn := range turns {
+		blocks = append(blocks, anthropicContentBlock{
+			Type:      "tool_result",
+			ToolUseID: turn.toolCall.ID,
+			Content:   turn.content,
+			IsError:   isToolErrorResult(turn.result),
+		})
+	}
+	return blocks, nil
+}
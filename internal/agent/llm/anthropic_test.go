@@ -0,0 +1,217 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const anthropicToolUseResponse = `{
+	"id": "msg_1",
+	"type": "message",
+	"role": "assistant",
+	"model": "claude-sonnet-4-20250514",
+	"content": [
+		{"type": "text", "text": "Let me add the numbers."},
+		{"type": "tool_use", "id": "toolu_1", "name": "add", "input": {"num1": 3, "num2": 5}}
+	],
+	"stop_reason": "tool_use",
+	"usage": {"input_tokens": 100, "output_tokens": 20}
+}`
+
+const anthropicEndTurnResponse = `{
+	"id": "msg_2",
+	"type": "message",
+	"role": "assistant",
+	"model": "claude-sonnet-4-20250514",
+	"content": [{"type": "text", "text": "{\"sum\":8}"}],
+	"stop_reason": "end_turn",
+	"usage": {"input_tokens": 50, "output_tokens": 10, "cache_creation_input_tokens": 30, "cache_read_input_tokens": 70}
+}`
+
+func newTestAnthropicLLM(server *scriptedServer, options ...anthropicLLMOption) *anthropicLLM {
+	return newAnthropicLLM(append([]anthropicLLMOption{
+		withAnthropicAPIKey("test"),
+		withAnthropicModel("claude-sonnet-4"),
+		withAnthropicBaseURL(server.URL),
+	}, options...)...)
+}
+
+func TestAnthropicLLMRoundTripsToolCalls(t *testing.T) {
+	// given
+	server := newScriptedServer(t, anthropicToolUseResponse, anthropicEndTurnResponse)
+	add := NewLLMTool(
+		WithLLMToolName("add"),
+		WithLLMToolDescription("Adds two numbers together"),
+		WithLLMToolParametersSchema(map[string]any{"type": "object"}),
+	)
+	a := newTestAnthropicLLM(server, withAnthropicTools([]LLMTool{add}))
+	msgs := []LLMMessage{
+		NewLLMMessage(LLMMessageTypeSystem, "You are a calculator"),
+		NewLLMMessage(LLMMessageTypeUser, `{"num1":3,"num2":5}`),
+	}
+
+	// when
+	toolCallMsg, err := a.Call(context.Background(), msgs)
+	require.NoError(t, err)
+	toolCallMsg.ToolResults = []LLMToolResult{
+		sumToolResult{BaseLLMToolResult: BaseLLMToolResult{ID: "toolu_1"}, Sum: 8},
+	}
+	finalMsg, err := a.Call(context.Background(), append(msgs, toolCallMsg))
+
+	// then
+	require.NoError(t, err)
+	assert.False(t, toolCallMsg.End)
+	assert.Equal(t, "Let me add the numbers.", toolCallMsg.Content)
+	require.Len(t, toolCallMsg.ToolCalls, 1)
+	assert.Equal(t, "toolu_1", toolCallMsg.ToolCalls[0].ID)
+	assert.Equal(t, map[string]any{"num1": 3.0, "num2": 5.0}, toolCallMsg.ToolCalls[0].Args)
+	assert.Equal(t, LLMUsage{PromptTokens: 100, CompletionTokens: 20, TotalTokens: 120}, toolCallMsg.Usage)
+
+	assert.True(t, finalMsg.End)
+	assert.Equal(t, `{"sum":8}`, finalMsg.Content)
+	assert.Equal(t, "claude-sonnet-4-20250514", finalMsg.Model)
+	assert.Equal(t, LLMUsage{PromptTokens: 150, CompletionTokens: 10, TotalTokens: 160, CachedTokens: 70}, finalMsg.Usage)
+
+	header := server.header(0)
+	assert.Equal(t, "test", header.Get("X-Api-Key"))
+	assert.Equal(t, anthropicVersion, header.Get("Anthropic-Version"))
+
+	request := server.request(1)
+	assert.Equal(t, "claude-sonnet-4", request["model"])
+	assert.Equal(t, "You are a calculator", request["system"])
+	tools := request["tools"].([]any)
+	require.Len(t, tools, 1)
+	assert.Equal(t, "add", tools[0].(map[string]any)["name"])
+	assert.Equal(t, map[string]any{"type": "object"}, tools[0].(map[string]any)["input_schema"])
+
+	sent := request["messages"].([]any)
+	require.Len(t, sent, 3)
+	assistant := sent[1].(map[string]any)
+	assert.Equal(t, "assistant", assistant["role"])
+	assistantBlocks := assistant["content"].([]any)
+	require.Len(t, assistantBlocks, 2)
+	toolUse := assistantBlocks[1].(map[string]any)
+	assert.Equal(t, "tool_use", toolUse["type"])
+	assert.Equal(t, "toolu_1", toolUse["id"])
+	assert.Equal(t, map[string]any{"num1": 3.0, "num2": 5.0}, toolUse["input"])
+
+	toolResult := sent[2].(map[string]any)["content"].([]any)[0].(map[string]any)
+	assert.Equal(t, "user", sent[2].(map[string]any)["role"])
+	assert.Equal(t, "tool_result", toolResult["type"])
+	assert.Equal(t, "toolu_1", toolResult["tool_use_id"])
+	assert.JSONEq(t, `{"id":"toolu_1","sum":8}`, toolResult["content"].(string))
+	assert.NotContains(t, toolResult, "is_error")
+}
+
+func TestAnthropicLLMMergesToolErrorsAndUserMessages(t *testing.T) {
+	// given
+	server := newScriptedServer(t, anthropicEndTurnResponse)
+	a := newTestAnthropicLLM(server)
+	toolCallMsg := LLMMessage{
+		Type:        LLMMessageTypeAssistant,
+		ToolCalls:   []LLMToolCall{NewRawLLMToolCall("toolu_1", "add", `{"num1":`)},
+		ToolResults: []LLMToolResult{NewLLMToolErrorResult("toolu_1", ErrInvalidArguments)},
+	}
+
+	// when
+	_, err := a.Call(context.Background(), []LLMMessage{
+		NewLLMMessage(LLMMessageTypeUser, "add 3 and 5"),
+		toolCallMsg,
+		NewLLMMessage(LLMMessageTypeUser, "Return the final answer"),
+	}, WithLLMCallToolsDisabled())
+
+	// then
+	require.NoError(t, err)
+	request := server.request(0)
+	assert.NotContains(t, request, "tool_choice")
+
+	sent := request["messages"].([]any)
+	require.Len(t, sent, 3)
+	assert.Equal(t, map[string]any{}, sent[1].(map[string]any)["content"].([]any)[0].(map[string]any)["input"])
+
+	user := sent[2].(map[string]any)["content"].([]any)
+	require.Len(t, user, 2)
+	assert.Equal(t, true, user[0].(map[string]any)["is_error"])
+	assert.Equal(t, "text", user[1].(map[string]any)["type"])
+}
+
+func TestAnthropicLLMMarksRestoredToolErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		result  LLMToolResult
+		isError bool
+	}{
+		{name: "error result", result: NewLLMToolErrorResult("toolu_1", ErrInvalidArguments), isError: true},
+		{name: "result with an error field", result: LLMRawToolResult{ID: "toolu_1", Raw: []byte(`{"id":"toolu_1","error":"none","sum":8}`)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// given
+			server := newScriptedServer(t, anthropicEndTurnResponse)
+			a := newTestAnthropicLLM(server)
+			data, err := json.Marshal(LLMMessage{
+				Type:        LLMMessageTypeAssistant,
+				ToolCalls:   []LLMToolCall{NewLLMToolCall("toolu_1", "add", map[string]any{})},
+				ToolResults: []LLMToolResult{tt.result},
+			})
+			require.NoError(t, err)
+			var restored LLMMessage
+			require.NoError(t, json.Unmarshal(data, &restored))
+
+			// when
+			_, err = a.Call(context.Background(), []LLMMessage{NewLLMMessage(LLMMessageTypeUser, "add 3 and 5"), restored})
+
+			// then
+			require.NoError(t, err)
+			require.IsType(t, LLMRawToolResult{}, restored.ToolResults[0])
+			toolResult := server.request(0)["messages"].([]any)[2].(map[string]any)["content"].([]any)[0].(map[string]any)
+			if tt.isError {
+				assert.Equal(t, true, toolResult["is_error"])
+			} else {
+				assert.NotContains(t, toolResult, "is_error")
+			}
+		})
+	}
+}
+
+func TestAnthropicLLMReportsAPIErrors(t *testing.T) {
+	// given
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+		_, _ = w.Write([]byte(`{"type":"error","error":{"type":"rate_limit_error","message":"Number of requests has exceeded your rate limit"}}`))
+	}))
+	t.Cleanup(server.Close)
+	a := newAnthropicLLM(withAnthropicBaseURL(server.URL))
+
+	// when
+	_, err := a.Call(context.Background(), []LLMMessage{NewLLMMessage(LLMMessageTypeUser, "hi")})
+
+	// then
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "status = 429")
+	assert.Contains(t, err.Error(), "rate_limit_error")
+}
+
+func TestAnthropicLLMSendsSamplingParameters(t *testing.T) {
+	// given
+	server := newScriptedServer(t, anthropicEndTurnResponse)
+	a := newTestAnthropicLLM(server, withAnthropicMaxTokens(512), withAnthropicTopP(0.9), withAnthropicStop([]string{"END"}))
+
+	// when
+	_, err := a.Call(context.Background(), []LLMMessage{NewLLMMessage(LLMMessageTypeUser, "hi")})
+
+	// then
+	require.NoError(t, err)
+	request := server.request(0)
+	assert.Equal(t, 512.0, request["max_tokens"])
+	assert.Equal(t, 0.9, request["top_p"])
+	assert.NotContains(t, request, "temperature")
+	assert.Equal(t, []any{"END"}, request["stop_sequences"])
+}
@@ -0,0 +1,101 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/openai/openai-go/option"
+)
+
+const azureOpenAIDefaultAPIVersion = "2024-10-21"
+
+// AzureOpenAIConfig configures the Azure OpenAI provider. The resource
+// endpoint, e.g. https://my-resource.openai.azure.com, is set as
+// LLMConfig.BaseURL. Requests are authenticated with the first of
+// TokenProvider, BearerToken or LLMConfig.APIKey that is set.
+type AzureOpenAIConfig struct {
+	// Deployment is the model deployment name. LLMConfig.Model is used when
+	// it is empty.
+	Deployment string `json:"deployment,omitempty"`
+	APIVersion string `json:"api_version,omitempty"`
+	// BearerToken is a Microsoft Entra ID access token.
+	BearerToken string `json:"-"`
+	// TokenProvider returns a fresh Microsoft Entra ID access token for every
+	// request.
+	TokenProvider func(ctx context.Context) (string, error) `json:"-"`
+}
+
+func init() {
+	RegisterLLM(LLMTypeAzureOpenAI, newAzureOpenAILLM)
+}
+
+// newAzureOpenAILLM reuses the OpenAI provider, routing its requests to the
+// deployment with Azure request options.
+func newAzureOpenAILLM(cfg LLMConfig, tools []LLMTool) (LLM, error) {
+	if cfg.BaseURL == "" {
+		return nil, fmt.Errorf("%w: Azure OpenAI requires the resource endpoint as base URL", ErrInvalidLLMConfig)
+	}
+	deployment := cfg.Azure.Deployment
+	if deployment == "" {
+		deployment = cfg.Model
+	}
+	if deployment == "" {
+		return nil, fmt.Errorf("%w: Azure OpenAI requires a deployment", ErrInvalidLLMConfig)
+	}
+	model := cfg.Model
+	if model == "" {
+		model = deployment
+	}
+	apiVersion := cfg.Azure.APIVersion
+	if apiVersion == "" {
+		apiVersion = azureOpenAIDefaultAPIVersion
+	}
+
+	auth, err := azureOpenAIAuth(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return newOpenAILLM(
+		withOpenAIBaseURL(strings.TrimSuffix(cfg.BaseURL, "/")+"/openai/deployments/"+url.PathEscape(deployment)+"/"),
+		withOpenAIRequestOptions(append(auth, option.WithQuery("api-version", apiVersion))...),
+		withOpenAIHeaders(cfg.Headers),
+		withOpenAIUnsupportedParameters(cfg.UnsupportedParameters),
+		withOpenAILLMModel(model),
+		withOpenAILLMTemperature(cfg.Temperature),
+		withOpenAIMaxTokens(cfg.MaxTokens),
+		withOpenAITopP(cfg.TopP),
+		withOpenAISeed(cfg.Seed),
+		withOpenAIStop(cfg.Stop),
+		withOpenAIPenalties(cfg.PresencePenalty, cfg.FrequencyPenalty),
+		withOpenAITimeout(cfg.Timeout),
+		withOpenAITools(tools),
+		withOpenAIStructuredOutputs(cfg.StructuredOutputs),
+		withOpenAIReasoning(cfg.IsReasoningModel(), cfg.ReasoningEffort),
+	), nil
+}
+
+// azureOpenAIAuth authenticates the requests. The Authorization header the
+// client sets from OPENAI_API_KEY is replaced or removed.
+func azureOpenAIAuth(cfg LLMConfig) ([]option.RequestOption, error) {
+	switch {
+	case cfg.Azure.TokenProvider != nil:
+		return []option.RequestOption{option.WithMiddleware(func(req *http.Request, next option.MiddlewareNext) (*http.Response, error) {
+			token, err := cfg.Azure.TokenProvider(req.Context())
+			if err != nil {
+				return nil, fmt.Errorf("failed to get Azure OpenAI token: %w", err)
+			}
+			req.Header.Set("Authorization", "Bearer "+token)
+			return next(req)
+		})}, nil
+	case cfg.Azure.BearerToken != "":
+		return []option.RequestOption{option.WithHeader("Authorization", "Bearer "+cfg.Azure.BearerToken)}, nil
+	case cfg.APIKey != "":
+		return []option.RequestOption{option.WithHeaderDel("Authorization"), option.WithHeader("Api-Key", cfg.APIKey)}, nil
+	default:
+		return nil, fmt.Errorf("%w: Azure OpenAI requires an API key or a bearer token", ErrInvalidLLMConfig)
+	}
+}
@@ -0,0 +1,95 @@
+package llm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAzureOpenAILLMRoutesToDeployment(t *testing.T) {
+	// given
+	t.Setenv("OPENAI_API_KEY", "openai-key")
+	server := newScriptedServer(t, toolCallCompletion, stopCompletion)
+	created, err := CreateLLM(LLMConfig{
+		Type:    LLMTypeAzureOpenAI,
+		APIKey:  "azure-key",
+		Model:   "gpt-4.1",
+		BaseURL: server.URL + "/",
+		Azure:   AzureOpenAIConfig{Deployment: "gpt-41-prod"},
+	}, map[string]LLMTool{"add": NewLLMTool(WithLLMToolName("add"))})
+	require.NoError(t, err)
+	msgs := []LLMMessage{NewLLMMessage(LLMMessageTypeUser, `{"num1":3,"num2":5}`)}
+
+	// when
+	toolCallMsg, err := created.Call(context.Background(), msgs)
+	require.NoError(t, err)
+	finalMsg, err := created.Call(context.Background(), msgs)
+
+	// then
+	require.NoError(t, err)
+	assert.False(t, toolCallMsg.End)
+	require.Len(t, toolCallMsg.ToolCalls, 1)
+	assert.Equal(t, "add", toolCallMsg.ToolCalls[0].ToolName)
+	assert.True(t, finalMsg.End)
+	assert.Equal(t, int64(150), finalMsg.Usage.TotalTokens)
+
+	assert.Equal(t, "/openai/deployments/gpt-41-prod/chat/completions?api-version="+azureOpenAIDefaultAPIVersion, server.url(0))
+	header := server.header(0)
+	assert.Equal(t, "azure-key", header.Get("Api-Key"))
+	assert.Empty(t, header.Get("Authorization"))
+	assert.Len(t, server.request(0)["tools"], 1)
+}
+
+func TestAzureOpenAILLMUsesBearerTokens(t *testing.T) {
+	// given
+	server := newScriptedServer(t, stopCompletion)
+	created, err := CreateLLM(LLMConfig{
+		Type:    LLMTypeAzureOpenAI,
+		Model:   "gpt-41-prod",
+		BaseURL: server.URL,
+		Azure: AzureOpenAIConfig{
+			APIVersion: "2025-01-01-preview",
+			TokenProvider: func(ctx context.Context) (string, error) {
+				return "entra-token", nil
+			},
+		},
+	}, nil)
+	require.NoError(t, err)
+
+	// when
+	_, err = created.Call(context.Background(), []LLMMessage{NewLLMMessage(LLMMessageTypeUser, "hi")})
+
+	// then
+	require.NoError(t, err)
+	assert.Equal(t, "/openai/deployments/gpt-41-prod/chat/completions?api-version=2025-01-01-preview", server.url(0))
+	assert.Equal(t, "Bearer entra-token", server.header(0).Get("Authorization"))
+	assert.Empty(t, server.header(0).Get("Api-Key"))
+}
+
+func TestAzureOpenAILLMRequiresEndpointDeploymentAndAuth(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     LLMConfig
+		wantErr string
+	}{
+		{name: "endpoint", cfg: LLMConfig{APIKey: "key", Model: "gpt-4.1"}, wantErr: "base URL"},
+		{name: "deployment", cfg: LLMConfig{APIKey: "key", BaseURL: "https://example.openai.azure.com"}, wantErr: "deployment"},
+		{name: "auth", cfg: LLMConfig{Model: "gpt-4.1", BaseURL: "https://example.openai.azure.com"}, wantErr: "API key or a bearer token"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// given
+			tt.cfg.Type = LLMTypeAzureOpenAI
+
+			// when
+			_, err := CreateLLM(tt.cfg, nil)
+
+			// then
+			require.ErrorIs(t, err, ErrInvalidLLMConfig)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
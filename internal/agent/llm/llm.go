@@ -3,28 +3,32 @@ package llm
 import (
 	"context"
 	"fmt"
+	"slices"
+	"strings"
 )
 
-var ErrUnsupportedLLMType = fmt.Errorf("unsupported LLM type")
+var (
+	ErrUnsupportedLLMType = fmt.Errorf("unsupported LLM type")
+	ErrInvalidLLMConfig   = fmt.Errorf("invalid LLM config")
+)
 
 type LLM interface {
-	Call(ctx context.Context, msgs []LLMMessage) (LLMMessage, error)
+	Call(ctx context.Context, msgs []LLMMessage, options ...LLMCallOption) (LLMMessage, error)
+	// Stream is the streaming counterpart of Call.
+	Stream(ctx context.Context, msgs []LLMMessage, options ...LLMCallOption) (LLMStream, error)
 }
 
+// CreateLLM creates an LLM with the provider registered for the config type.
 func CreateLLM(cfg LLMConfig, tools map[string]LLMTool) (LLM, error) {
-	switch cfg.Type {
-	case LLMTypeOpenAI:
-		return newOpenAILLM(
-			withOpenAIAPIKey(cfg.APIKey),
-			withOpenAILLMModel(cfg.Model),
-			withOpenAILLMTemperature(cfg.Temperature),
-			withOpenAITools(toSlice(tools)),
-		), nil
-	default:
-		return nil, ErrUnsupportedLLMType
+	factory, ok := lookupLLMFactory(cfg.Type)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedLLMType, cfg.Type)
 	}
+	return factory(cfg, toSlice(tools))
 }
 
+// toSlice returns the tools sorted by name, so providers send them in a stable
+// order.
 func toSlice(tools map[string]LLMTool) []LLMTool {
 	if len(tools) == 0 {
 		return nil
@@ -33,5 +37,8 @@ func toSlice(tools map[string]LLMTool) []LLMTool {
 	for _, tool := range tools {
 		slice = append(slice, tool)
 	}
+	slices.SortFunc(slice, func(a, b LLMTool) int {
+		return strings.Compare(a.Name, b.Name)
+	})
 	return slice
 }
@@ -0,0 +1,48 @@
+package llm
+
+type LLMCallOptions struct {
+	DisableTools       bool
+	ResponseSchemaName string
+	ResponseSchema     map[string]any
+	// ResponseSchemaStrict asks the LLM to enforce the schema exactly. Strict
+	// schemas must require every property and close every object.
+	ResponseSchemaStrict bool
+}
+
+type LLMCallOption func(options *LLMCallOptions)
+
+func NewLLMCallOptions(options ...LLMCallOption) LLMCallOptions {
+	callOptions := &LLMCallOptions{}
+	for _, opt := range options {
+		opt(callOptions)
+	}
+	return *callOptions
+}
+
+// WithLLMCallToolsDisabled forbids the model from calling tools in this call,
+// so it has to answer with a message.
+func WithLLMCallToolsDisabled() LLMCallOption {
+	return func(options *LLMCallOptions) {
+		options.DisableTools = true
+	}
+}
+
+// WithLLMCallResponseSchema asks the LLM to answer with JSON matching the
+// schema. LLMs configured without structured outputs ignore it and rely on the
+// schema being described in the prompt.
+func WithLLMCallResponseSchema(name string, schema map[string]any) LLMCallOption {
+	return func(options *LLMCallOptions) {
+		options.ResponseSchemaName = name
+		options.ResponseSchema = schema
+	}
+}
+
+// WithLLMCallStrictResponseSchema is WithLLMCallResponseSchema with the schema
+// enforced in strict mode.
+func WithLLMCallStrictResponseSchema(name string, schema map[string]any) LLMCallOption {
+	return func(options *LLMCallOptions) {
+		options.ResponseSchemaName = name
+		options.ResponseSchema = schema
+		options.ResponseSchemaStrict = true
+	}
+}
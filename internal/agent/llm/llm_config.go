@@ -1,9 +1,20 @@
 package llm
 
+import (
+	"strings"
+	"time"
+)
+
 type LLMType string
 
 const (
-	LLMTypeOpenAI LLMType = "openai"
+	LLMTypeOpenAI    LLMType = "openai"
+	LLMTypeAnthropic LLMType = "anthropic"
+	// LLMTypeOpenAIResponses uses the OpenAI Responses API instead of Chat
+	// Completions.
+	LLMTypeOpenAIResponses LLMType = "openai_responses"
+	// LLMTypeAzureOpenAI is configured with LLMConfig.Azure.
+	LLMTypeAzureOpenAI LLMType = "azure_openai"
 )
 
 type LLMConfig struct {
@@ -11,4 +22,70 @@ type LLMConfig struct {
 	APIKey      string  `json:"api_key"`
 	Model       string  `json:"model"`
 	Temperature float64 `json:"temperature"`
+	// MaxTokens caps the output tokens of a call, reasoning tokens included.
+	MaxTokens int64 `json:"max_tokens,omitempty"`
+	// Timeout limits every request to the provider.
+	Timeout time.Duration `json:"timeout,omitempty"`
+	// TopP, Stop and the penalties are not sent to reasoning models, which
+	// reject them.
+	TopP float64 `json:"top_p,omitempty"`
+	// Seed makes sampling deterministic on providers that support it.
+	Seed             *int64   `json:"seed,omitempty"`
+	Stop             []string `json:"stop,omitempty"`
+	PresencePenalty  float64  `json:"presence_penalty,omitempty"`
+	FrequencyPenalty float64  `json:"frequency_penalty,omitempty"`
+	// StructuredOutputs enforces the output schema natively for providers that
+	// support it. Otherwise the schema is only described in the system prompt.
+	StructuredOutputs bool `json:"structured_outputs"`
+
+	// BaseURL points the provider to another server, e.g. a local Ollama, vLLM
+	// or LM Studio serving the OpenAI API or an internal gateway.
+	BaseURL      string            `json:"base_url,omitempty"`
+	Organization string            `json:"organization,omitempty"`
+	Headers      map[string]string `json:"headers,omitempty"`
+	// UnsupportedParameters are request parameters the server rejects, e.g.
+	// "temperature". They are removed from every request.
+	UnsupportedParameters []string `json:"unsupported_parameters,omitempty"`
+	// DisableResponseChaining makes the Responses API send the full history
+	// with every request instead of continuing the previous response, and
+	// stops OpenAI from storing the responses. Chained requests keep the
+	// history on the server, out of reach of context window management.
+	DisableResponseChaining bool `json:"disable_response_chaining,omitempty"`
+
+	Azure AzureOpenAIConfig `json:"azure,omitzero"`
+
+	// ReasoningModel marks the model as a reasoning model. Known reasoning
+	// models are detected by name when it is nil.
+	ReasoningModel *bool `json:"reasoning_model,omitempty"`
+	// ReasoningEffort is "low", "medium" or "high" for reasoning models.
+	ReasoningEffort string `json:"reasoning_effort,omitempty"`
+}
+
+// reasoningModelPrefixes are the prefixes of reasoning model names. Reasoning
+// models reject temperature and take system prompts as developer messages.
+var reasoningModelPrefixes = []string{"o1", "o3", "o4", "gpt-5"}
+
+// nonReasoningModelPrefixes are exceptions to reasoningModelPrefixes.
+var nonReasoningModelPrefixes = []string{"gpt-5-chat"}
+
+// IsReasoningModel reports whether the configured model is a reasoning model.
+func (c LLMConfig) IsReasoningModel() bool {
+	if c.ReasoningModel != nil {
+		return *c.ReasoningModel
+	}
+	model := c.Model
+	if c.Type == LLMTypeAzureOpenAI && c.Azure.Deployment != "" && model == "" {
+		model = c.Azure.Deployment
+	}
+	for _, prefix := range nonReasoningModelPrefixes {
+		if strings.HasPrefix(model, prefix) {
+			return false
+		}
+	}
+	for _, prefix := range reasoningModelPrefixes {
+		if strings.HasPrefix(model, prefix) {
+			return true
+		}
+	}
+	return false
 }
@@ -0,0 +1,33 @@
+package llm_test
+
+import (
+	"testing"
+
+	"reddit-analyzer/internal/agent/llm"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLLMConfigIsReasoningModel(t *testing.T) {
+	enabled, disabled := true, false
+
+	tests := []struct {
+		name string
+		cfg  llm.LLMConfig
+		want bool
+	}{
+		{name: "o-series", cfg: llm.LLMConfig{Model: "o3-mini"}, want: true},
+		{name: "gpt-5", cfg: llm.LLMConfig{Model: "gpt-5-mini"}, want: true},
+		{name: "gpt-5 chat", cfg: llm.LLMConfig{Model: "gpt-5-chat-latest"}},
+		{name: "chat model", cfg: llm.LLMConfig{Model: "gpt-4.1"}},
+		{name: "explicitly enabled", cfg: llm.LLMConfig{Model: "deepseek-r1", ReasoningModel: &enabled}, want: true},
+		{name: "explicitly disabled", cfg: llm.LLMConfig{Model: "o3", ReasoningModel: &disabled}},
+		{name: "azure deployment", cfg: llm.LLMConfig{Type: llm.LLMTypeAzureOpenAI, Azure: llm.AzureOpenAIConfig{Deployment: "o4-mini"}}, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.cfg.IsReasoningModel())
+		})
+	}
+}
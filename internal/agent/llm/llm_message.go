@@ -1,5 +1,10 @@
 package llm
 
+import (
+	"encoding/json"
+	"fmt"
+)
+
 type LLMMessageType string
 
 const (
@@ -14,6 +19,20 @@ type LLMMessage struct {
 	ToolCalls   []LLMToolCall   `json:"tool_call,omitempty"`
 	ToolResults []LLMToolResult `json:"tool_result,omitempty"`
 	End         bool            `json:"end,omitempty"`
+	Model       string          `json:"model,omitempty"`
+	Usage       LLMUsage        `json:"usage,omitzero"`
+	// ResponseID identifies the message on providers that keep the
+	// conversation state, so later calls only send the messages after it.
+	ResponseID string         `json:"response_id,omitempty"`
+	Reasoning  []LLMReasoning `json:"reasoning,omitempty"`
+}
+
+// LLMReasoning is a reasoning item of a reasoning model. EncryptedContent lets
+// stateless requests pass the reasoning back to the model.
+type LLMReasoning struct {
+	ID               string `json:"id"`
+	Summary          string `json:"summary,omitempty"`
+	EncryptedContent string `json:"encrypted_content,omitempty"`
 }
 
 func NewLLMMessage(msgType LLMMessageType, content string) LLMMessage {
@@ -22,3 +41,59 @@ func NewLLMMessage(msgType LLMMessageType, content string) LLMMessage {
 		Content: content,
 	}
 }
+
+// UnmarshalJSON decodes tool results as LLMRawToolResult because their
+// concrete types are only known to the tools that produced them.
+func (m *LLMMessage) UnmarshalJSON(data []byte) error {
+	type message LLMMessage
+	var decoded struct {
+		message
+		ToolResults []json.RawMessage `json:"tool_result,omitempty"`
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+
+	*m = LLMMessage(decoded.message)
+	m.ToolResults = nil
+	for _, raw := range decoded.ToolResults {
+		result, err := NewLLMRawToolResult(raw)
+		if err != nil {
+			return err
+		}
+		m.ToolResults = append(m.ToolResults, result)
+	}
+	return nil
+}
+
+// toolResultTurn is a tool result encoded for a provider, together with the
+// tool call it answers.
+type toolResultTurn struct {
+	toolCall LLMToolCall
+	result   LLMToolResult
+	content  string
+}
+
+// toolResultTurns pairs the tool calls of the message with their results in
+// the order the model requested the calls, which providers expect the results
+// in. Calls without a result yet are skipped.
+func toolResultTurns(msg LLMMessage) ([]toolResultTurn, error) {
+	results := make(map[string]LLMToolResult, len(msg.ToolResults))
+	for _, result := range msg.ToolResults {
+		results[result.GetID()] = result
+	}
+
+	var turns []toolResultTurn
+	for _, toolCall := range msg.ToolCalls {
+		result, ok := results[toolCall.ID]
+		if !ok {
+			continue
+		}
+		content, err := json.Marshal(result)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal tool result: id = %s, err = %w", toolCall.ID, err)
+		}
+		turns = append(turns, toolResultTurn{toolCall: toolCall, result: result, content: string(content)})
+	}
+	return turns, nil
+}
@@ -0,0 +1,52 @@
+package llm
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+)
+
+// LLMFactory creates an LLM from its config and the tools it can call.
+type LLMFactory func(cfg LLMConfig, tools []LLMTool) (LLM, error)
+
+var (
+	providersMu sync.RWMutex
+	providers   = make(map[LLMType]LLMFactory)
+)
+
+// RegisterLLM makes a provider available to CreateLLM under the LLM type. It is
+// meant to be called from the init function of the provider package and
+// panics when the type is already registered or the factory is nil.
+func RegisterLLM(llmType LLMType, factory LLMFactory) {
+	providersMu.Lock()
+	defer providersMu.Unlock()
+
+	if factory == nil {
+		panic(fmt.Sprintf("llm: factory of %s is nil", llmType))
+	}
+	if _, exists := providers[llmType]; exists {
+		panic(fmt.Sprintf("llm: provider %s is already registered", llmType))
+	}
+	providers[llmType] = factory
+}
+
+// Providers returns the sorted types of the registered providers.
+func Providers() []LLMType {
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+
+	types := make([]LLMType, 0, len(providers))
+	for llmType := range providers {
+		types = append(types, llmType)
+	}
+	slices.Sort(types)
+	return types
+}
+
+func lookupLLMFactory(llmType LLMType) (LLMFactory, bool) {
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+
+	factory, ok := providers[llmType]
+	return factory, ok
+}
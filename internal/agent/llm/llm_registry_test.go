@@ -0,0 +1,75 @@
+package llm_test
+
+import (
+	"context"
+	"testing"
+
+	"reddit-analyzer/internal/agent/llm"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeLLM struct {
+	cfg   llm.LLMConfig
+	tools []llm.LLMTool
+}
+
+func (f *fakeLLM) Call(ctx context.Context, msgs []llm.LLMMessage, options ...llm.LLMCallOption) (llm.LLMMessage, error) {
+	return llm.NewLLMMessage(llm.LLMMessageTypeAssistant, f.cfg.Model), nil
+}
+
+func (f *fakeLLM) Stream(ctx context.Context, msgs []llm.LLMMessage, options ...llm.LLMCallOption) (llm.LLMStream, error) {
+	msg, err := f.Call(ctx, msgs, options...)
+	if err != nil {
+		return nil, err
+	}
+	return llm.NewLLMMessageStream(msg), nil
+}
+
+const fakeLLMType llm.LLMType = "fake"
+
+func init() {
+	llm.RegisterLLM(fakeLLMType, func(cfg llm.LLMConfig, tools []llm.LLMTool) (llm.LLM, error) {
+		return &fakeLLM{cfg: cfg, tools: tools}, nil
+	})
+}
+
+func TestCreateLLMUsesRegisteredProvider(t *testing.T) {
+	// given
+	cfg := llm.LLMConfig{Type: fakeLLMType, Model: "fake-model", Temperature: 0.5}
+	tools := map[string]llm.LLMTool{
+		"subtract": llm.NewLLMTool(llm.WithLLMToolName("subtract")),
+		"add":      llm.NewLLMTool(llm.WithLLMToolName("add")),
+	}
+
+	// when
+	created, err := llm.CreateLLM(cfg, tools)
+
+	// then
+	require.NoError(t, err)
+	fake := created.(*fakeLLM)
+	assert.Equal(t, cfg, fake.cfg)
+	require.Len(t, fake.tools, 2)
+	assert.Equal(t, "add", fake.tools[0].Name)
+	assert.Equal(t, "subtract", fake.tools[1].Name)
+	assert.Contains(t, llm.Providers(), fakeLLMType)
+	assert.Contains(t, llm.Providers(), llm.LLMTypeOpenAI)
+}
+
+func TestCreateLLMFailsForUnknownType(t *testing.T) {
+	// when
+	_, err := llm.CreateLLM(llm.LLMConfig{Type: "unknown"}, nil)
+
+	// then
+	require.ErrorIs(t, err, llm.ErrUnsupportedLLMType)
+	assert.Contains(t, err.Error(), "unknown")
+}
+
+func TestRegisterLLMPanicsOnDuplicateType(t *testing.T) {
+	assert.Panics(t, func() {
+		llm.RegisterLLM(llm.LLMTypeOpenAI, func(cfg llm.LLMConfig, tools []llm.LLMTool) (llm.LLM, error) {
+			return nil, nil
+		})
+	})
+}
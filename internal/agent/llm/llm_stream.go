@@ -0,0 +1,80 @@
+package llm
+
+type LLMStreamEventType string
+
+const (
+	LLMStreamEventTypeContent  LLMStreamEventType = "content"
+	LLMStreamEventTypeToolCall LLMStreamEventType = "tool_call"
+)
+
+// LLMStreamEvent is a single update of a streamed response. Content events
+// carry the content delta. Tool call events carry the arguments delta and the
+// tool call assembled so far, whose RawArgs grow until the call is complete.
+type LLMStreamEvent struct {
+	Type     LLMStreamEventType `json:"type"`
+	Delta    string             `json:"delta"`
+	ToolCall LLMToolCall        `json:"tool_call,omitzero"`
+}
+
+// LLMStream iterates over the events of a streamed response:
+//
+//	for stream.Next() {
+//		event := stream.Current()
+//	}
+//	if err := stream.Err(); err != nil {
+//		...
+//	}
+//	msg := stream.Message()
+//
+// Message returns the same message Call would have returned once Next has
+// returned false without an error.
+type LLMStream interface {
+	Next() bool
+	Current() LLMStreamEvent
+	Message() LLMMessage
+	Err() error
+	Close() error
+}
+
+// NewLLMMessageStream replays a complete message as a stream. It lets LLMs
+// without native streaming implement Stream on top of Call.
+func NewLLMMessageStream(msg LLMMessage) LLMStream {
+	var events []LLMStreamEvent
+	if msg.Content != "" {
+		events = append(events, LLMStreamEvent{Type: LLMStreamEventTypeContent, Delta: msg.Content})
+	}
+	for _, toolCall := range msg.ToolCalls {
+		events = append(events, LLMStreamEvent{Type: LLMStreamEventTypeToolCall, Delta: toolCall.ArgsJSON(), ToolCall: toolCall})
+	}
+	return &messageStream{msg: msg, events: events, index: -1}
+}
+
+type messageStream struct {
+	msg    LLMMessage
+	events []LLMStreamEvent
+	index  int
+}
+
+func (s *messageStream) Next() bool {
+	if s.index+1 >= len(s.events) {
+		return false
+	}
+	s.index++
+	return true
+}
+
+func (s *messageStream) Current() LLMStreamEvent {
+	return s.events[s.index]
+}
+
+func (s *messageStream) Message() LLMMessage {
+	return s.msg
+}
+
+func (s *messageStream) Err() error {
+	return nil
+}
+
+func (s *messageStream) Close() error {
+	return nil
+}
@@ -1,14 +1,24 @@
 package llm
 
-import "errors"
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
 
 var ErrInvalidArguments = errors.New("invalid arguments")
 
+type LLMToolFunc func(ctx context.Context, id string, args map[string]any) (LLMToolResult, error)
+
 type LLMTool struct {
-	Name             string                                                      `json:"name"`
-	ParametersSchema map[string]any                                              `json:"parameters_schema"`
-	Description      string                                                      `json:"description"`
-	Call             func(id string, args map[string]any) (LLMToolResult, error) `json:"-"`
+	Name             string         `json:"name"`
+	ParametersSchema map[string]any `json:"parameters_schema"`
+	Description      string         `json:"description"`
+	Call             LLMToolFunc    `json:"-"`
+	// Timeout bounds a single call of the tool. Zero means no timeout.
+	Timeout time.Duration `json:"-"`
 }
 
 type LLMToolOption func(tool *LLMTool)
@@ -39,10 +49,19 @@ func WithLLMToolParametersSchema(schema map[string]any) LLMToolOption {
 	}
 }
 
+// WithLLMToolCall sets a call function that does not need the context.
 func WithLLMToolCall[T LLMToolResult](callFunc func(id string, args map[string]any) (T, error)) LLMToolOption {
+	return WithLLMToolCallContext(func(_ context.Context, id string, args map[string]any) (T, error) {
+		return callFunc(id, args)
+	})
+}
+
+// WithLLMToolCallContext sets a call function that receives the context of the
+// agent run, so it can stop when the run is cancelled or the tool times out.
+func WithLLMToolCallContext[T LLMToolResult](callFunc func(ctx context.Context, id string, args map[string]any) (T, error)) LLMToolOption {
 	return func(tool *LLMTool) {
-		tool.Call = func(id string, args map[string]any) (LLMToolResult, error) {
-			result, err := callFunc(id, args)
+		tool.Call = func(ctx context.Context, id string, args map[string]any) (LLMToolResult, error) {
+			result, err := callFunc(ctx, id, args)
 			if err != nil {
 				return nil, err
 			}
@@ -51,6 +70,12 @@ func WithLLMToolCall[T LLMToolResult](callFunc func(id string, args map[string]a
 	}
 }
 
+func WithLLMToolTimeout(timeout time.Duration) LLMToolOption {
+	return func(tool *LLMTool) {
+		tool.Timeout = timeout
+	}
+}
+
 type LLMToolResult interface {
 	GetID() string
 }
@@ -63,10 +88,49 @@ func (r BaseLLMToolResult) GetID() string {
 	return r.ID
 }
 
+// LLMToolErrorResult reports to the model that a tool call was not executed
+// successfully.
+type LLMToolErrorResult struct {
+	BaseLLMToolResult
+	Error string `json:"error"`
+}
+
+func NewLLMToolErrorResult(id string, err error) LLMToolErrorResult {
+	return LLMToolErrorResult{
+		BaseLLMToolResult: BaseLLMToolResult{
+			ID: id,
+		},
+		Error: err.Error(),
+	}
+}
+
+// isToolErrorResult reports whether the result is an LLMToolErrorResult, also
+// when it was restored from its JSON encoding as an LLMRawToolResult.
+func isToolErrorResult(result LLMToolResult) bool {
+	switch r := result.(type) {
+	case LLMToolErrorResult:
+		return true
+	case LLMRawToolResult:
+		// an error result encodes to its id and error message only
+		var decoded map[string]any
+		if err := json.Unmarshal(r.Raw, &decoded); err != nil || len(decoded) != 2 {
+			return false
+		}
+		_, hasID := decoded["id"]
+		_, hasError := decoded["error"].(string)
+		return hasID && hasError
+	default:
+		return false
+	}
+}
+
 type LLMToolCall struct {
 	ID       string         `json:"id"`
 	ToolName string         `json:"tool_name"`
 	Args     map[string]any `json:"args"`
+	// RawArgs keeps the arguments exactly as the model sent them, including
+	// arguments that are not valid JSON.
+	RawArgs string `json:"raw_args,omitempty"`
 }
 
 func NewLLMToolCall(id string, toolName string, args map[string]any) LLMToolCall {
@@ -76,3 +140,69 @@ func NewLLMToolCall(id string, toolName string, args map[string]any) LLMToolCall
 		Args:     args,
 	}
 }
+
+// NewRawLLMToolCall creates a tool call from the JSON arguments sent by the
+// model. Malformed arguments are kept in RawArgs and reported by ParseArgs.
+func NewRawLLMToolCall(id string, toolName string, rawArgs string) LLMToolCall {
+	toolCall := NewLLMToolCall(id, toolName, nil)
+	toolCall.RawArgs = rawArgs
+	if args, err := toolCall.ParseArgs(); err == nil {
+		toolCall.Args = args
+	}
+	return toolCall
+}
+
+// ParseArgs returns the call arguments or ErrInvalidArguments when the model
+// sent arguments that are not a JSON object.
+func (c LLMToolCall) ParseArgs() (map[string]any, error) {
+	if c.Args != nil || c.RawArgs == "" {
+		return c.Args, nil
+	}
+	var args map[string]any
+	if err := json.Unmarshal([]byte(c.RawArgs), &args); err != nil {
+		return nil, fmt.Errorf("%w: malformed JSON: %s", ErrInvalidArguments, err)
+	}
+	return args, nil
+}
+
+// ArgsJSON returns the call arguments encoded as the JSON the model sent.
+func (c LLMToolCall) ArgsJSON() string {
+	if c.RawArgs != "" {
+		return c.RawArgs
+	}
+	if c.Args == nil {
+		return "{}"
+	}
+	data, err := json.Marshal(c.Args)
+	if err != nil {
+		return "{}"
+	}
+	return string(data)
+}
+
+// LLMRawToolResult is a tool result decoded from its JSON encoding, for example
+// when a conversation is restored from a checkpoint. It encodes back to the
+// same JSON.
+type LLMRawToolResult struct {
+	ID  string
+	Raw json.RawMessage
+}
+
+func NewLLMRawToolResult(raw json.RawMessage) (LLMRawToolResult, error) {
+	var base BaseLLMToolResult
+	if err := json.Unmarshal(raw, &base); err != nil {
+		return LLMRawToolResult{}, fmt.Errorf("failed to decode tool result: %w", err)
+	}
+	return LLMRawToolResult{
+		ID:  base.ID,
+		Raw: raw,
+	}, nil
+}
+
+func (r LLMRawToolResult) GetID() string {
+	return r.ID
+}
+
+func (r LLMRawToolResult) MarshalJSON() ([]byte, error) {
+	return r.Raw, nil
+}
@@ -0,0 +1,100 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/invopop/jsonschema"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+type LLMTypedToolResult[T any] struct {
+	BaseLLMToolResult
+	Result T `json:"result"`
+}
+
+// NewTypedTool creates a tool whose parameters schema is reflected from In.
+// Arguments are validated against the schema and decoded into In before the
+// call; unknown fields are rejected with ErrInvalidArguments.
+func NewTypedTool[In any, Out any](callFunc func(ctx context.Context, input In) (Out, error), options ...LLMToolOption) LLMTool {
+	schema := reflectParametersSchema(new(In))
+	tool := NewLLMTool(append([]LLMToolOption{WithLLMToolParametersSchema(schema)}, options...)...)
+	tool.Call = func(ctx context.Context, id string, args map[string]any) (LLMToolResult, error) {
+		if err := ValidateLLMToolArgs(schema, args); err != nil {
+			return nil, err
+		}
+		input, err := decodeArgs[In](args)
+		if err != nil {
+			return nil, err
+		}
+		result, err := callFunc(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+		return LLMTypedToolResult[Out]{
+			BaseLLMToolResult: BaseLLMToolResult{
+				ID: id,
+			},
+			Result: result,
+		}, nil
+	}
+	return tool
+}
+
+// ValidateLLMToolArgs checks the arguments against the tool parameters schema.
+// Violations are returned as ErrInvalidArguments listing every invalid field.
+func ValidateLLMToolArgs(schema map[string]any, args map[string]any) error {
+	if schema == nil {
+		return nil
+	}
+	if args == nil {
+		args = make(map[string]any)
+	}
+
+	validationRes, err := gojsonschema.Validate(gojsonschema.NewGoLoader(schema), gojsonschema.NewGoLoader(args))
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidArguments, err)
+	}
+	if validationRes.Valid() {
+		return nil
+	}
+
+	descriptions := make([]string, 0, len(validationRes.Errors()))
+	for _, resultError := range validationRes.Errors() {
+		descriptions = append(descriptions, resultError.String())
+	}
+	return fmt.Errorf("%w: %s", ErrInvalidArguments, strings.Join(descriptions, "; "))
+}
+
+func reflectParametersSchema(input any) map[string]any {
+	reflector := jsonschema.Reflector{DoNotReference: true}
+	schemaBytes, err := json.Marshal(reflector.Reflect(input))
+	if err != nil {
+		return nil
+	}
+
+	var schema map[string]any
+	if err := json.Unmarshal(schemaBytes, &schema); err != nil {
+		return nil
+	}
+	delete(schema, "$schema")
+	return schema
+}
+
+func decodeArgs[In any](args map[string]any) (In, error) {
+	var input In
+	data, err := json.Marshal(args)
+	if err != nil {
+		return input, fmt.Errorf("%w: %s", ErrInvalidArguments, err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&input); err != nil {
+		return input, fmt.Errorf("%w: %s", ErrInvalidArguments, err)
+	}
+	return input, nil
+}
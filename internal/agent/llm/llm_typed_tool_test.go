@@ -0,0 +1,95 @@
+package llm_test
+
+import (
+	"context"
+	"testing"
+
+	"reddit-analyzer/internal/agent/llm"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type addInput struct {
+	Num1 int `json:"num1" jsonschema:"description=First number"`
+	Num2 int `json:"num2" jsonschema:"description=Second number"`
+}
+
+type addOutput struct {
+	Sum int `json:"sum"`
+}
+
+func createTypedAddTool() llm.LLMTool {
+	return llm.NewTypedTool(
+		func(ctx context.Context, input addInput) (addOutput, error) {
+			return addOutput{Sum: input.Num1 + input.Num2}, nil
+		},
+		llm.WithLLMToolName("add"),
+		llm.WithLLMToolDescription("Adds two numbers together"),
+	)
+}
+
+func TestTypedToolReflectsParametersSchema(t *testing.T) {
+	// when
+	tool := createTypedAddTool()
+
+	// then
+	assert.Equal(t, "add", tool.Name)
+	assert.Equal(t, "object", tool.ParametersSchema["type"])
+	assert.Equal(t, []any{"num1", "num2"}, tool.ParametersSchema["required"])
+	assert.NotContains(t, tool.ParametersSchema, "$schema")
+
+	properties := tool.ParametersSchema["properties"].(map[string]any)
+	assert.Equal(t, "integer", properties["num1"].(map[string]any)["type"])
+	assert.Equal(t, "First number", properties["num1"].(map[string]any)["description"])
+}
+
+func TestTypedToolCall(t *testing.T) {
+	tool := createTypedAddTool()
+
+	tests := []struct {
+		name    string
+		args    map[string]any
+		wantSum int
+		wantErr string
+	}{
+		{
+			name:    "valid arguments",
+			args:    map[string]any{"num1": 3.0, "num2": 5.0},
+			wantSum: 8,
+		},
+		{
+			name:    "wrong type",
+			args:    map[string]any{"num1": "three", "num2": 5.0},
+			wantErr: "num1: Invalid type",
+		},
+		{
+			name:    "missing field",
+			args:    map[string]any{"num1": 3.0},
+			wantErr: "num2 is required",
+		},
+		{
+			name:    "unknown field",
+			args:    map[string]any{"num1": 3.0, "num2": 5.0, "num3": 1.0},
+			wantErr: "num3",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// when
+			result, err := tool.Call(context.Background(), "call_1", tt.args)
+
+			// then
+			if tt.wantErr != "" {
+				require.ErrorIs(t, err, llm.ErrInvalidArguments)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			typed := result.(llm.LLMTypedToolResult[addOutput])
+			assert.Equal(t, "call_1", typed.GetID())
+			assert.Equal(t, tt.wantSum, typed.Result.Sum)
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package llm
+
+import "strings"
+
+// LLMUsage is the token usage of LLM calls. CachedTokens are the part of the
+// prompt tokens served from the provider cache and ReasoningTokens are the
+// part of the completion tokens spent on reasoning.
+type LLMUsage struct {
+	PromptTokens     int64 `json:"prompt_tokens"`
+	CompletionTokens int64 `json:"completion_tokens"`
+	TotalTokens      int64 `json:"total_tokens"`
+	CachedTokens     int64 `json:"cached_tokens"`
+	ReasoningTokens  int64 `json:"reasoning_tokens"`
+}
+
+func (u LLMUsage) Add(other LLMUsage) LLMUsage {
+	return LLMUsage{
+		PromptTokens:     u.PromptTokens + other.PromptTokens,
+		CompletionTokens: u.CompletionTokens + other.CompletionTokens,
+		TotalTokens:      u.TotalTokens + other.TotalTokens,
+		CachedTokens:     u.CachedTokens + other.CachedTokens,
+		ReasoningTokens:  u.ReasoningTokens + other.ReasoningTokens,
+	}
+}
+
+// LLMPrice is the USD price of one million tokens. Cached prompt tokens are
+// billed at the prompt price when CachedPromptPerMillion is zero.
+type LLMPrice struct {
+	PromptPerMillion       float64 `json:"prompt_per_million"`
+	CachedPromptPerMillion float64 `json:"cached_prompt_per_million"`
+	CompletionPerMillion   float64 `json:"completion_per_million"`
+}
+
+func (p LLMPrice) Cost(usage LLMUsage) float64 {
+	cachedPrice := p.CachedPromptPerMillion
+	if cachedPrice == 0 {
+		cachedPrice = p.PromptPerMillion
+	}
+	uncached := usage.PromptTokens - usage.CachedTokens
+	return (float64(uncached)*p.PromptPerMillion +
+		float64(usage.CachedTokens)*cachedPrice +
+		float64(usage.CompletionTokens)*p.CompletionPerMillion) / 1_000_000
+}
+
+// LLMPriceTable maps model names to their prices. Dated model snapshots such as
+// "gpt-4o-2024-08-06" use the price of the longest model name they start with.
+type LLMPriceTable map[string]LLMPrice
+
+func (t LLMPriceTable) Price(model string) (LLMPrice, bool) {
+	if price, ok := t[model]; ok {
+		return price, true
+	}
+
+	var match string
+	for name := range t {
+		if strings.HasPrefix(model, name) && len(name) > len(match) {
+			match = name
+		}
+	}
+	if match == "" {
+		return LLMPrice{}, false
+	}
+	return t[match], true
+}
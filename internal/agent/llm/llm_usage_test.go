@@ -0,0 +1,39 @@
+package llm_test
+
+import (
+	"testing"
+
+	"reddit-analyzer/internal/agent/llm"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLLMPriceTable(t *testing.T) {
+	prices := llm.LLMPriceTable{
+		"gpt-4.1":      {PromptPerMillion: 2, CachedPromptPerMillion: 0.5, CompletionPerMillion: 8},
+		"gpt-4.1-mini": {PromptPerMillion: 0.4, CompletionPerMillion: 1.6},
+	}
+	usage := llm.LLMUsage{PromptTokens: 1_000_000, CompletionTokens: 500_000, CachedTokens: 400_000}
+
+	tests := []struct {
+		name     string
+		model    string
+		wantCost float64
+		wantOK   bool
+	}{
+		{name: "exact model", model: "gpt-4.1", wantCost: 0.6*2 + 0.4*0.5 + 0.5*8, wantOK: true},
+		{name: "snapshot of the longest model", model: "gpt-4.1-mini-2025-04-14", wantCost: 0.4 + 0.5*1.6, wantOK: true},
+		{name: "unknown model", model: "o3"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// when
+			price, ok := prices.Price(tt.model)
+
+			// then
+			assert.Equal(t, tt.wantOK, ok)
+			assert.InDelta(t, tt.wantCost, price.Cost(usage), 1e-9)
+		})
+	}
+}
@@ -2,11 +2,13 @@ package llm
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
+	"time"
 
 	"github.com/openai/openai-go"
 	"github.com/openai/openai-go/option"
+	"github.com/openai/openai-go/packages/ssestream"
+	"github.com/openai/openai-go/shared"
 )
 
 const (
@@ -20,6 +22,21 @@ type openAILLM struct {
 	temperature float64
 	model       openai.ChatModel
 	tools       []LLMTool
+
+	structuredOutputs bool
+	maxTokens         int64
+	topP              float64
+	seed              *int64
+	stop              []string
+	presencePenalty   float64
+	frequencyPenalty  float64
+
+	// reasoning models get developer messages and no temperature or
+	// sampling parameters.
+	reasoning       bool
+	reasoningEffort string
+	// requestOptions configure the client, they are applied to every request.
+	requestOptions []option.RequestOption
 }
 
 type openAILLMOption func(o *openAILLM)
@@ -38,7 +55,95 @@ func withOpenAILLMModel(model string) openAILLMOption {
 func withOpenAIAPIKey(apiKey string) openAILLMOption {
 	return func(o *openAILLM) {
 		o.apiKey = apiKey
-		o.client = openai.NewClient(option.WithAPIKey(apiKey))
+		o.requestOptions = append(o.requestOptions, option.WithAPIKey(apiKey))
+	}
+}
+
+// withOpenAIBaseURL points the client to an OpenAI compatible server such as
+// Ollama, vLLM or LM Studio.
+func withOpenAIBaseURL(baseURL string) openAILLMOption {
+	return func(o *openAILLM) {
+		if baseURL != "" {
+			o.requestOptions = append(o.requestOptions, option.WithBaseURL(baseURL))
+		}
+	}
+}
+
+func withOpenAIOrganization(organization string) openAILLMOption {
+	return func(o *openAILLM) {
+		if organization != "" {
+			o.requestOptions = append(o.requestOptions, option.WithOrganization(organization))
+		}
+	}
+}
+
+func withOpenAIHeaders(headers map[string]string) openAILLMOption {
+	return func(o *openAILLM) {
+		for key, value := range headers {
+			o.requestOptions = append(o.requestOptions, option.WithHeader(key, value))
+		}
+	}
+}
+
+func withOpenAIRequestOptions(options ...option.RequestOption) openAILLMOption {
+	return func(o *openAILLM) {
+		o.requestOptions = append(o.requestOptions, options...)
+	}
+}
+
+func withOpenAIMaxTokens(maxTokens int64) openAILLMOption {
+	return func(o *openAILLM) {
+		o.maxTokens = maxTokens
+	}
+}
+
+func withOpenAITopP(topP float64) openAILLMOption {
+	return func(o *openAILLM) {
+		o.topP = topP
+	}
+}
+
+func withOpenAISeed(seed *int64) openAILLMOption {
+	return func(o *openAILLM) {
+		o.seed = seed
+	}
+}
+
+func withOpenAIStop(stop []string) openAILLMOption {
+	return func(o *openAILLM) {
+		o.stop = stop
+	}
+}
+
+func withOpenAIPenalties(presence float64, frequency float64) openAILLMOption {
+	return func(o *openAILLM) {
+		o.presencePenalty = presence
+		o.frequencyPenalty = frequency
+	}
+}
+
+func withOpenAITimeout(timeout time.Duration) openAILLMOption {
+	return func(o *openAILLM) {
+		if timeout > 0 {
+			o.requestOptions = append(o.requestOptions, option.WithRequestTimeout(timeout))
+		}
+	}
+}
+
+func withOpenAIReasoning(enabled bool, effort string) openAILLMOption {
+	return func(o *openAILLM) {
+		o.reasoning = enabled
+		o.reasoningEffort = effort
+	}
+}
+
+// withOpenAIUnsupportedParameters removes the parameters from the request
+// bodies for servers that reject them.
+func withOpenAIUnsupportedParameters(parameters []string) openAILLMOption {
+	return func(o *openAILLM) {
+		for _, parameter := range parameters {
+			o.requestOptions = append(o.requestOptions, option.WithJSONDel(parameter))
+		}
 	}
 }
 
@@ -48,16 +153,54 @@ func withOpenAITools(tools []LLMTool) openAILLMOption {
 	}
 }
 
+func withOpenAIStructuredOutputs(enabled bool) openAILLMOption {
+	return func(o *openAILLM) {
+		o.structuredOutputs = enabled
+	}
+}
+
+func init() {
+	RegisterLLM(LLMTypeOpenAI, func(cfg LLMConfig, tools []LLMTool) (LLM, error) {
+		return newOpenAILLM(openAIOptionsFromConfig(cfg, tools)...), nil
+	})
+}
+
+func openAIOptionsFromConfig(cfg LLMConfig, tools []LLMTool) []openAILLMOption {
+	return []openAILLMOption{
+		withOpenAIAPIKey(cfg.APIKey),
+		withOpenAIBaseURL(cfg.BaseURL),
+		withOpenAIOrganization(cfg.Organization),
+		withOpenAIHeaders(cfg.Headers),
+		withOpenAIUnsupportedParameters(cfg.UnsupportedParameters),
+		withOpenAILLMModel(cfg.Model),
+		withOpenAILLMTemperature(cfg.Temperature),
+		withOpenAIMaxTokens(cfg.MaxTokens),
+		withOpenAITopP(cfg.TopP),
+		withOpenAISeed(cfg.Seed),
+		withOpenAIStop(cfg.Stop),
+		withOpenAIPenalties(cfg.PresencePenalty, cfg.FrequencyPenalty),
+		withOpenAITimeout(cfg.Timeout),
+		withOpenAITools(tools),
+		withOpenAIStructuredOutputs(cfg.StructuredOutputs),
+		withOpenAIReasoning(cfg.IsReasoningModel(), cfg.ReasoningEffort),
+	}
+}
+
 func newOpenAILLM(options ...openAILLMOption) *openAILLM {
 	llm := &openAILLM{}
 	for _, opt := range options {
 		opt(llm)
 	}
+	llm.client = openai.NewClient(llm.requestOptions...)
 	return llm
 }
 
-func (o *openAILLM) Call(ctx context.Context, msgs []LLMMessage) (LLMMessage, error) {
-	params := o.createParameters(msgs)
+func (o *openAILLM) Call(ctx context.Context, msgs []LLMMessage, options ...LLMCallOption) (LLMMessage, error) {
+	params, err := o.createParameters(msgs, NewLLMCallOptions(options...))
+	if err != nil {
+		return LLMMessage{}, err
+	}
+
 	completion, err := o.client.Chat.Completions.New(ctx, params)
 	if err != nil {
 		return LLMMessage{}, fmt.Errorf("OpenAI API call failed: %w", err)
@@ -67,37 +210,206 @@ func (o *openAILLM) Call(ctx context.Context, msgs []LLMMessage) (LLMMessage, er
 		return LLMMessage{}, fmt.Errorf("no response from OpenAI")
 	}
 
-	return o.newLLMMessage(completion.Choices[0]), nil
+	return o.newLLMMessage(*completion), nil
+}
+
+func (o *openAILLM) Stream(ctx context.Context, msgs []LLMMessage, options ...LLMCallOption) (LLMStream, error) {
+	params, err := o.createParameters(msgs, NewLLMCallOptions(options...))
+	if err != nil {
+		return nil, err
+	}
+	params.StreamOptions = openai.ChatCompletionStreamOptionsParam{
+		IncludeUsage: openai.Bool(true),
+	}
+
+	return &openAIStream{
+		llm:    o,
+		stream: o.client.Chat.Completions.NewStreaming(ctx, params),
+	}, nil
+}
+
+// openAIStream assembles the streamed chunks with the SDK accumulator, so the
+// final message is built the same way as for a blocking call.
+type openAIStream struct {
+	llm    *openAILLM
+	stream *ssestream.Stream[openai.ChatCompletionChunk]
+	acc    openai.ChatCompletionAccumulator
+	// usage keeps the token details, the accumulator only adds up the totals
+	usage   openai.CompletionUsage
+	pending []LLMStreamEvent
+	current LLMStreamEvent
+	err     error
+}
+
+func (s *openAIStream) Next() bool {
+	for len(s.pending) == 0 {
+		if s.err != nil || !s.stream.Next() {
+			return false
+		}
+		chunk := s.stream.Current()
+		if !s.acc.AddChunk(chunk) {
+			s.err = fmt.Errorf("OpenAI API stream returned an inconsistent chunk: id = %s", chunk.ID)
+			return false
+		}
+		if chunk.JSON.Usage.Valid() {
+			s.usage = chunk.Usage
+		}
+		s.pending = s.createEvents(chunk)
+	}
+
+	s.current, s.pending = s.pending[0], s.pending[1:]
+	return true
 }
 
-func (o *openAILLM) newLLMMessage(choice openai.ChatCompletionChoice) LLMMessage {
+func (s *openAIStream) createEvents(chunk openai.ChatCompletionChunk) []LLMStreamEvent {
+	if len(chunk.Choices) == 0 {
+		return nil
+	}
+
+	var events []LLMStreamEvent
+	delta := chunk.Choices[0].Delta
+	if delta.Content != "" {
+		events = append(events, LLMStreamEvent{Type: LLMStreamEventTypeContent, Delta: delta.Content})
+	}
+	for _, toolCallDelta := range delta.ToolCalls {
+		toolCall := s.acc.Choices[0].Message.ToolCalls[toolCallDelta.Index]
+		events = append(events, LLMStreamEvent{
+			Type:     LLMStreamEventTypeToolCall,
+			Delta:    toolCallDelta.Function.Arguments,
+			ToolCall: NewRawLLMToolCall(toolCall.ID, toolCall.Function.Name, toolCall.Function.Arguments),
+		})
+	}
+	return events
+}
+
+func (s *openAIStream) Current() LLMStreamEvent {
+	return s.current
+}
+
+func (s *openAIStream) Message() LLMMessage {
+	if len(s.acc.Choices) == 0 {
+		return LLMMessage{}
+	}
+	completion := s.acc.ChatCompletion
+	completion.Usage.PromptTokensDetails = s.usage.PromptTokensDetails
+	completion.Usage.CompletionTokensDetails = s.usage.CompletionTokensDetails
+	return s.llm.newLLMMessage(completion)
+}
+
+func (s *openAIStream) Err() error {
+	if s.err != nil {
+		return s.err
+	}
+	if err := s.stream.Err(); err != nil {
+		return fmt.Errorf("OpenAI API stream failed: %w", err)
+	}
+	if len(s.acc.Choices) == 0 {
+		return fmt.Errorf("no response from OpenAI")
+	}
+	return nil
+}
+
+func (s *openAIStream) Close() error {
+	return s.stream.Close()
+}
+
+func (o *openAILLM) newLLMMessage(completion openai.ChatCompletion) LLMMessage {
+	choice := completion.Choices[0]
+	model := completion.Model
+	if model == "" {
+		model = o.model
+	}
 	return LLMMessage{
 		Type:      LLMMessageTypeAssistant,
 		Content:   choice.Message.Content,
 		ToolCalls: o.createLLMToolCalls(choice),
 		End:       choice.FinishReason == openAIFinishReasonStop || choice.FinishReason == openAIFinishReasonLength,
+		Model:     model,
+		Usage: LLMUsage{
+			PromptTokens:     completion.Usage.PromptTokens,
+			CompletionTokens: completion.Usage.CompletionTokens,
+			TotalTokens:      completion.Usage.TotalTokens,
+			CachedTokens:     completion.Usage.PromptTokensDetails.CachedTokens,
+			ReasoningTokens:  completion.Usage.CompletionTokensDetails.ReasoningTokens,
+		},
 	}
 }
 
 func (o *openAILLM) createLLMToolCalls(choice openai.ChatCompletionChoice) []LLMToolCall {
 	var res []LLMToolCall
 	for _, toolCall := range choice.Message.ToolCalls {
-		args := make(map[string]any)
-		if err := json.Unmarshal([]byte(toolCall.Function.Arguments), &args); err != nil {
-			continue
-		}
-
-		res = append(res, NewLLMToolCall(toolCall.ID, toolCall.Function.Name, args))
+		res = append(res, NewRawLLMToolCall(toolCall.ID, toolCall.Function.Name, toolCall.Function.Arguments))
 	}
 	return res
 }
 
-func (o *openAILLM) createParameters(messages []LLMMessage) openai.ChatCompletionNewParams {
-	return openai.ChatCompletionNewParams{
-		Messages:    o.createMessages(messages),
-		Model:       o.model,
-		Temperature: openai.Float(o.temperature),
-		Tools:       o.createToolParams(),
+func (o *openAILLM) createParameters(messages []LLMMessage, options LLMCallOptions) (openai.ChatCompletionNewParams, error) {
+	openAIMessages, err := o.createMessages(messages)
+	if err != nil {
+		return openai.ChatCompletionNewParams{}, err
+	}
+
+	params := openai.ChatCompletionNewParams{
+		Messages: openAIMessages,
+		Model:    o.model,
+		Tools:    o.createToolParams(),
+	}
+	if o.reasoning {
+		params.ReasoningEffort = shared.ReasoningEffort(o.reasoningEffort)
+	} else {
+		params.Temperature = openai.Float(o.temperature)
+	}
+	o.setSamplingParameters(&params)
+	// Tools stay declared because the history may reference earlier tool calls.
+	if options.DisableTools && len(params.Tools) > 0 {
+		params.ToolChoice = openai.ChatCompletionToolChoiceOptionUnionParam{
+			OfAuto: openai.String(string(openai.ChatCompletionToolChoiceOptionAutoNone)),
+		}
+	}
+	if o.structuredOutputs && options.ResponseSchema != nil {
+		params.ResponseFormat = openai.ChatCompletionNewParamsResponseFormatUnion{
+			OfJSONSchema: &openai.ResponseFormatJSONSchemaParam{
+				JSONSchema: openai.ResponseFormatJSONSchemaJSONSchemaParam{
+					Name:   options.ResponseSchemaName,
+					Schema: options.ResponseSchema,
+					Strict: openai.Bool(options.ResponseSchemaStrict),
+				},
+			},
+		}
+	}
+
+	return params, nil
+}
+
+// setSamplingParameters sets the optional parameters that are configured.
+// Reasoning models only accept max_completion_tokens, while many OpenAI
+// compatible servers only know max_tokens.
+func (o *openAILLM) setSamplingParameters(params *openai.ChatCompletionNewParams) {
+	if o.maxTokens > 0 {
+		if o.reasoning {
+			params.MaxCompletionTokens = openai.Int(o.maxTokens)
+		} else {
+			params.MaxTokens = openai.Int(o.maxTokens)
+		}
+	}
+	if o.seed != nil {
+		params.Seed = openai.Int(*o.seed)
+	}
+	// reasoning models reject the sampling parameters
+	if o.reasoning {
+		return
+	}
+	if o.topP > 0 {
+		params.TopP = openai.Float(o.topP)
+	}
+	if len(o.stop) > 0 {
+		params.Stop = openai.ChatCompletionNewParamsStopUnion{OfStringArray: o.stop}
+	}
+	if o.presencePenalty != 0 {
+		params.PresencePenalty = openai.Float(o.presencePenalty)
+	}
+	if o.frequencyPenalty != 0 {
+		params.FrequencyPenalty = openai.Float(o.frequencyPenalty)
 	}
 }
 
@@ -117,19 +429,65 @@ func (o *openAILLM) createToolParams() []openai.ChatCompletionToolParam {
 	return toolParams
 }
 
-func (o *openAILLM) createMessages(msgs []LLMMessage) []openai.ChatCompletionMessageParamUnion {
+func (o *openAILLM) createMessages(msgs []LLMMessage) ([]openai.ChatCompletionMessageParamUnion, error) {
 	openAIMessages := make([]openai.ChatCompletionMessageParamUnion, 0, len(msgs))
 
 	for _, msg := range msgs {
 		switch msg.Type {
 		case LLMMessageTypeSystem:
-			openAIMessages = append(openAIMessages, openai.SystemMessage(msg.Content))
+			if o.reasoning {
+				openAIMessages = append(openAIMessages, openai.DeveloperMessage(msg.Content))
+			} else {
+				openAIMessages = append(openAIMessages, openai.SystemMessage(msg.Content))
+			}
 		case LLMMessageTypeUser:
 			openAIMessages = append(openAIMessages, openai.UserMessage(msg.Content))
 		case LLMMessageTypeAssistant:
-			openAIMessages = append(openAIMessages, openai.AssistantMessage(msg.Content))
+			openAIMessages = append(openAIMessages, o.createAssistantMessage(msg))
+			toolMessages, err := o.createToolMessages(msg)
+			if err != nil {
+				return nil, err
+			}
+			openAIMessages = append(openAIMessages, toolMessages...)
 		}
 	}
 
-	return openAIMessages
+	return openAIMessages, nil
+}
+
+func (o *openAILLM) createAssistantMessage(msg LLMMessage) openai.ChatCompletionMessageParamUnion {
+	if len(msg.ToolCalls) == 0 {
+		return openai.AssistantMessage(msg.Content)
+	}
+
+	assistant := openai.ChatCompletionAssistantMessageParam{
+		ToolCalls: make([]openai.ChatCompletionMessageToolCallParam, 0, len(msg.ToolCalls)),
+	}
+	if msg.Content != "" {
+		assistant.Content.OfString = openai.String(msg.Content)
+	}
+	for _, toolCall := range msg.ToolCalls {
+		assistant.ToolCalls = append(assistant.ToolCalls, openai.ChatCompletionMessageToolCallParam{
+			ID: toolCall.ID,
+			Function: openai.ChatCompletionMessageToolCallFunctionParam{
+				Name:      toolCall.ToolName,
+				Arguments: toolCall.ArgsJSON(),
+			},
+		})
+	}
+
+	return openai.ChatCompletionMessageParamUnion{OfAssistant: &assistant}
+}
+
+func (o *openAILLM) createToolMessages(msg LLMMessage) ([]openai.ChatCompletionMessageParamUnion, error) {
+	turns, err := toolResultTurns(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	toolMessages := make([]openai.ChatCompletionMessageParamUnion, 0, len(turns))
+	for _, turn := range turns {
+		toolMessages = append(toolMessages, openai.ToolMessage(turn.content, turn.toolCall.ID))
+	}
+	return toolMessages, nil
 }
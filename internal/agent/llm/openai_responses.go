@@ -0,0 +1,247 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/responses"
+	"github.com/openai/openai-go/shared"
+)
+
+const (
+	openAIResponsesItemFunctionCall = "function_call"
+	openAIResponsesItemReasoning    = "reasoning"
+)
+
+// openAIResponsesLLM calls the OpenAI Responses API with the client and the
+// settings of an openAILLM. With chaining enabled every call continues the
+// last response in the history through previous_response_id, so only the
+// messages after it are sent. Otherwise the full history is sent, responses
+// are not stored and reasoning items are passed back encrypted.
+type openAIResponsesLLM struct {
+	openAI   *openAILLM
+	chaining bool
+}
+
+func init() {
+	RegisterLLM(LLMTypeOpenAIResponses, func(cfg LLMConfig, tools []LLMTool) (LLM, error) {
+		return &openAIResponsesLLM{
+			openAI:   newOpenAILLM(openAIOptionsFromConfig(cfg, tools)...),
+			chaining: !cfg.DisableResponseChaining,
+		}, nil
+	})
+}
+
+func (o *openAIResponsesLLM) Call(ctx context.Context, msgs []LLMMessage, options ...LLMCallOption) (LLMMessage, error) {
+	params, err := o.createParameters(msgs, NewLLMCallOptions(options...))
+	if err != nil {
+		return LLMMessage{}, err
+	}
+
+	response, err := o.openAI.client.Responses.New(ctx, params)
+	if err != nil {
+		return LLMMessage{}, fmt.Errorf("OpenAI API call failed: %w", err)
+	}
+	if response.Status == responses.ResponseStatusFailed {
+		return LLMMessage{}, fmt.Errorf("OpenAI response failed: code = %s, message = %s", response.Error.Code, response.Error.Message)
+	}
+
+	return o.newLLMMessage(response), nil
+}
+
+// Stream replays the response of Call.
+func (o *openAIResponsesLLM) Stream(ctx context.Context, msgs []LLMMessage, options ...LLMCallOption) (LLMStream, error) {
+	msg, err := o.Call(ctx, msgs, options...)
+	if err != nil {
+		return nil, err
+	}
+	return NewLLMMessageStream(msg), nil
+}
+
+func (o *openAIResponsesLLM) newLLMMessage(response *responses.Response) LLMMessage {
+	msg := LLMMessage{
+		Type:    LLMMessageTypeAssistant,
+		Content: response.OutputText(),
+		Model:   response.Model,
+		Usage: LLMUsage{
+			PromptTokens:     response.Usage.InputTokens,
+			CompletionTokens: response.Usage.OutputTokens,
+			TotalTokens:      response.Usage.TotalTokens,
+			CachedTokens:     response.Usage.InputTokensDetails.CachedTokens,
+			ReasoningTokens:  response.Usage.OutputTokensDetails.ReasoningTokens,
+		},
+	}
+	if o.chaining {
+		msg.ResponseID = response.ID
+	}
+
+	for _, item := range response.Output {
+		switch item.Type {
+		case openAIResponsesItemFunctionCall:
+			msg.ToolCalls = append(msg.ToolCalls, NewRawLLMToolCall(item.CallID, item.Name, item.Arguments))
+		case openAIResponsesItemReasoning:
+			summary := make([]string, 0, len(item.Summary))
+			for _, part := range item.Summary {
+				summary = append(summary, part.Text)
+			}
+			msg.Reasoning = append(msg.Reasoning, LLMReasoning{
+				ID:               item.ID,
+				Summary:          strings.Join(summary, "\n\n"),
+				EncryptedContent: item.EncryptedContent,
+			})
+		}
+	}
+	msg.End = len(msg.ToolCalls) == 0
+	return msg
+}
+
+func (o *openAIResponsesLLM) createParameters(msgs []LLMMessage, options LLMCallOptions) (responses.ResponseNewParams, error) {
+	var instructions []string
+	for _, msg := range msgs {
+		if msg.Type == LLMMessageTypeSystem {
+			instructions = append(instructions, msg.Content)
+		}
+	}
+
+	params := responses.ResponseNewParams{
+		Model: o.openAI.model,
+		Tools: o.createToolParams(),
+	}
+	if o.openAI.reasoning {
+		params.Reasoning = shared.ReasoningParam{Effort: shared.ReasoningEffort(o.openAI.reasoningEffort)}
+	} else {
+		params.Temperature = openai.Float(o.openAI.temperature)
+		if o.openAI.topP > 0 {
+			params.TopP = openai.Float(o.openAI.topP)
+		}
+	}
+	// the Responses API has no seed, stop sequences and penalties
+	if o.openAI.maxTokens > 0 {
+		params.MaxOutputTokens = openai.Int(o.openAI.maxTokens)
+	}
+	// instructions are not carried over from the previous response
+	if len(instructions) > 0 {
+		params.Instructions = openai.String(strings.Join(instructions, "\n\n"))
+	}
+
+	input, err := o.createInput(msgs, &params)
+	if err != nil {
+		return responses.ResponseNewParams{}, err
+	}
+	params.Input = responses.ResponseNewParamsInputUnion{OfInputItemList: input}
+
+	if !o.chaining {
+		params.Store = openai.Bool(false)
+		params.Include = []responses.ResponseIncludable{responses.ResponseIncludableReasoningEncryptedContent}
+	}
+	if options.DisableTools && len(params.Tools) > 0 {
+		params.ToolChoice = responses.ResponseNewParamsToolChoiceUnion{
+			OfToolChoiceMode: openai.Opt(responses.ToolChoiceOptionsNone),
+		}
+	}
+	if o.openAI.structuredOutputs && options.ResponseSchema != nil {
+		params.Text = responses.ResponseTextConfigParam{
+			Format: responses.ResponseFormatTextConfigUnionParam{
+				OfJSONSchema: &responses.ResponseFormatTextJSONSchemaConfigParam{
+					Name:   options.ResponseSchemaName,
+					Schema: options.ResponseSchema,
+					Strict: openai.Bool(options.ResponseSchemaStrict),
+				},
+			},
+		}
+	}
+
+	return params, nil
+}
+
+// createInput converts the messages after the last stored response to input
+// items and sets the response as the previous response.
+func (o *openAIResponsesLLM) createInput(msgs []LLMMessage, params *responses.ResponseNewParams) (responses.ResponseInputParam, error) {
+	var input responses.ResponseInputParam
+	if o.chaining {
+		for i := len(msgs) - 1; i >= 0; i-- {
+			if msgs[i].ResponseID == "" {
+				continue
+			}
+			params.PreviousResponseID = openai.String(msgs[i].ResponseID)
+			outputs, err := o.createFunctionCallOutputs(msgs[i])
+			if err != nil {
+				return nil, err
+			}
+			input = append(input, outputs...)
+			msgs = msgs[i+1:]
+			break
+		}
+	}
+
+	for _, msg := range msgs {
+		switch msg.Type {
+		case LLMMessageTypeUser:
+			input = append(input, responses.ResponseInputItemParamOfMessage(msg.Content, responses.EasyInputMessageRoleUser))
+		case LLMMessageTypeAssistant:
+			input = append(input, o.createAssistantItems(msg)...)
+			outputs, err := o.createFunctionCallOutputs(msg)
+			if err != nil {
+				return nil, err
+			}
+			input = append(input, outputs...)
+		}
+	}
+	return input, nil
+}
+
+func (o *openAIResponsesLLM) createAssistantItems(msg LLMMessage) []responses.ResponseInputItemUnionParam {
+	var items []responses.ResponseInputItemUnionParam
+	for _, reasoning := range msg.Reasoning {
+		// reasoning can only be passed back encrypted or by a stored response
+		if reasoning.EncryptedContent == "" {
+			continue
+		}
+		item := responses.ResponseReasoningItemParam{
+			ID:               reasoning.ID,
+			Summary:          []responses.ResponseReasoningItemSummaryParam{},
+			EncryptedContent: openai.String(reasoning.EncryptedContent),
+		}
+		if reasoning.Summary != "" {
+			item.Summary = append(item.Summary, responses.ResponseReasoningItemSummaryParam{Text: reasoning.Summary})
+		}
+		items = append(items, responses.ResponseInputItemUnionParam{OfReasoning: &item})
+	}
+	if msg.Content != "" {
+		items = append(items, responses.ResponseInputItemParamOfMessage(msg.Content, responses.EasyInputMessageRoleAssistant))
+	}
+	for _, toolCall := range msg.ToolCalls {
+		items = append(items, responses.ResponseInputItemParamOfFunctionCall(toolCall.ArgsJSON(), toolCall.ID, toolCall.ToolName))
+	}
+	return items
+}
+
+func (o *openAIResponsesLLM) createFunctionCallOutputs(msg LLMMessage) ([]responses.ResponseInputItemUnionParam, error) {
+	turns, err := toolResultTurns(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]responses.ResponseInputItemUnionParam, 0, len(turns))
+	for _, turn := range turns {
+		items = append(items, responses.ResponseInputItemParamOfFunctionCallOutput(turn.toolCall.ID, turn.content))
+	}
+	return items, nil
+}
+
+func (o *openAIResponsesLLM) createToolParams() []responses.ToolUnionParam {
+	toolParams := make([]responses.ToolUnionParam, 0, len(o.openAI.tools))
+	for _, tool := range o.openAI.tools {
+		toolParams = append(toolParams, responses.ToolUnionParam{
+			OfFunction: &responses.FunctionToolParam{
+				Name:        tool.Name,
+				Description: openai.String(tool.Description),
+				Parameters:  tool.ParametersSchema,
+				Strict:      openai.Bool(false),
+			},
+		})
+	}
+	return toolParams
+}
@@ -0,0 +1,198 @@
+package llm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const responsesFunctionCallResponse = `{
+	"id": "resp_1",
+	"object": "response",
+	"created_at": 1,
+	"status": "completed",
+	"model": "o4-mini-2025-04-16",
+	"output": [
+		{"type": "reasoning", "id": "rs_1", "summary": [{"type": "summary_text", "text": "Add the numbers."}], "encrypted_content": "encrypted"},
+		{"type": "function_call", "id": "fc_1", "call_id": "call_1", "name": "add", "arguments": "{\"num1\":3,\"num2\":5}", "status": "completed"}
+	],
+	"usage": {
+		"input_tokens": 100,
+		"input_tokens_details": {"cached_tokens": 40},
+		"output_tokens": 30,
+		"output_tokens_details": {"reasoning_tokens": 20},
+		"total_tokens": 130
+	}
+}`
+
+const responsesMessageResponse = `{
+	"id": "resp_2",
+	"object": "response",
+	"created_at": 2,
+	"status": "completed",
+	"model": "o4-mini-2025-04-16",
+	"output": [
+		{"type": "message", "id": "msg_1", "role": "assistant", "status": "completed", "content": [{"type": "output_text", "text": "{\"sum\":8}", "annotations": []}]}
+	],
+	"usage": {
+		"input_tokens": 150,
+		"input_tokens_details": {"cached_tokens": 0},
+		"output_tokens": 10,
+		"output_tokens_details": {"reasoning_tokens": 0},
+		"total_tokens": 160
+	}
+}`
+
+func newTestOpenAIResponsesLLM(t *testing.T, server *scriptedServer, cfg LLMConfig) LLM {
+	cfg.Type = LLMTypeOpenAIResponses
+	cfg.APIKey = "test"
+	cfg.Model = "o4-mini"
+	cfg.BaseURL = server.URL
+	created, err := CreateLLM(cfg, map[string]LLMTool{
+		"add": NewLLMTool(WithLLMToolName("add"), WithLLMToolParametersSchema(map[string]any{"type": "object"})),
+	})
+	require.NoError(t, err)
+	return created
+}
+
+func callWithToolResult(t *testing.T, o LLM, options ...LLMCallOption) (LLMMessage, LLMMessage) {
+	msgs := []LLMMessage{
+		NewLLMMessage(LLMMessageTypeSystem, "You are a calculator"),
+		NewLLMMessage(LLMMessageTypeUser, `{"num1":3,"num2":5}`),
+	}
+	toolCallMsg, err := o.Call(context.Background(), msgs, options...)
+	require.NoError(t, err)
+	toolCallMsg.ToolResults = []LLMToolResult{
+		sumToolResult{BaseLLMToolResult: BaseLLMToolResult{ID: "call_1"}, Sum: 8},
+	}
+	finalMsg, err := o.Call(context.Background(), append(msgs, toolCallMsg), options...)
+	require.NoError(t, err)
+	return toolCallMsg, finalMsg
+}
+
+func TestOpenAIResponsesLLMChainsPreviousResponse(t *testing.T) {
+	// given
+	server := newScriptedServer(t, responsesFunctionCallResponse, responsesMessageResponse)
+	o := newTestOpenAIResponsesLLM(t, server, LLMConfig{ReasoningEffort: "low"})
+
+	// when
+	toolCallMsg, finalMsg := callWithToolResult(t, o)
+
+	// then
+	assert.False(t, toolCallMsg.End)
+	assert.Equal(t, "resp_1", toolCallMsg.ResponseID)
+	assert.Equal(t, []LLMToolCall{NewRawLLMToolCall("call_1", "add", `{"num1":3,"num2":5}`)}, toolCallMsg.ToolCalls)
+	assert.Equal(t, []LLMReasoning{{ID: "rs_1", Summary: "Add the numbers.", EncryptedContent: "encrypted"}}, toolCallMsg.Reasoning)
+	assert.Equal(t, LLMUsage{PromptTokens: 100, CompletionTokens: 30, TotalTokens: 130, CachedTokens: 40, ReasoningTokens: 20}, toolCallMsg.Usage)
+	assert.Equal(t, "o4-mini-2025-04-16", toolCallMsg.Model)
+
+	assert.True(t, finalMsg.End)
+	assert.Equal(t, `{"sum":8}`, finalMsg.Content)
+	assert.Equal(t, "/responses", server.url(1))
+
+	first := server.request(0)
+	assert.Equal(t, "You are a calculator", first["instructions"])
+	assert.NotContains(t, first, "temperature")
+	assert.Equal(t, map[string]any{"effort": "low"}, first["reasoning"])
+	assert.NotContains(t, first, "previous_response_id")
+	require.Len(t, first["input"], 1)
+	tool := first["tools"].([]any)[0].(map[string]any)
+	assert.Equal(t, "function", tool["type"])
+	assert.Equal(t, "add", tool["name"])
+
+	second := server.request(1)
+	assert.Equal(t, "resp_1", second["previous_response_id"])
+	assert.Equal(t, "You are a calculator", second["instructions"])
+	assert.NotContains(t, second, "store")
+	input := second["input"].([]any)
+	require.Len(t, input, 1)
+	output := input[0].(map[string]any)
+	assert.Equal(t, "function_call_output", output["type"])
+	assert.Equal(t, "call_1", output["call_id"])
+	assert.JSONEq(t, `{"id":"call_1","sum":8}`, output["output"].(string))
+}
+
+func TestOpenAIResponsesLLMSendsFullHistoryWithoutChaining(t *testing.T) {
+	// given
+	server := newScriptedServer(t, responsesFunctionCallResponse, responsesMessageResponse)
+	o := newTestOpenAIResponsesLLM(t, server, LLMConfig{DisableResponseChaining: true})
+
+	// when
+	toolCallMsg, _ := callWithToolResult(t, o)
+
+	// then
+	assert.Empty(t, toolCallMsg.ResponseID)
+
+	second := server.request(1)
+	assert.NotContains(t, second, "previous_response_id")
+	assert.Equal(t, false, second["store"])
+	assert.Equal(t, []any{"reasoning.encrypted_content"}, second["include"])
+
+	input := second["input"].([]any)
+	require.Len(t, input, 4)
+	assert.Equal(t, "user", input[0].(map[string]any)["role"])
+
+	reasoning := input[1].(map[string]any)
+	assert.Equal(t, "reasoning", reasoning["type"])
+	assert.Equal(t, "rs_1", reasoning["id"])
+	assert.Equal(t, "encrypted", reasoning["encrypted_content"])
+
+	call := input[2].(map[string]any)
+	assert.Equal(t, "function_call", call["type"])
+	assert.Equal(t, "call_1", call["call_id"])
+	assert.Equal(t, "function_call_output", input[3].(map[string]any)["type"])
+}
+
+func TestOpenAIResponsesLLMSendsResponseSchema(t *testing.T) {
+	// given
+	server := newScriptedServer(t, responsesMessageResponse)
+	o := newTestOpenAIResponsesLLM(t, server, LLMConfig{StructuredOutputs: true})
+	schema := map[string]any{"type": "object"}
+
+	// when
+	_, err := o.Call(context.Background(), []LLMMessage{NewLLMMessage(LLMMessageTypeUser, "hi")},
+		WithLLMCallStrictResponseSchema("agent_result", schema),
+		WithLLMCallToolsDisabled(),
+	)
+
+	// then
+	require.NoError(t, err)
+	request := server.request(0)
+	assert.Equal(t, "none", request["tool_choice"])
+	format := request["text"].(map[string]any)["format"].(map[string]any)
+	assert.Equal(t, "json_schema", format["type"])
+	assert.Equal(t, "agent_result", format["name"])
+	assert.Equal(t, true, format["strict"])
+	assert.Equal(t, schema, format["schema"])
+}
+
+func TestOpenAIResponsesLLMSendsSamplingParameters(t *testing.T) {
+	chatModel := false
+	tests := []struct {
+		name     string
+		cfg      LLMConfig
+		wantTopP any
+	}{
+		{name: "reasoning model", cfg: LLMConfig{MaxTokens: 512, TopP: 0.9}},
+		{name: "chat model", cfg: LLMConfig{MaxTokens: 512, TopP: 0.9, ReasoningModel: &chatModel}, wantTopP: 0.9},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// given
+			server := newScriptedServer(t, responsesMessageResponse)
+			o := newTestOpenAIResponsesLLM(t, server, tt.cfg)
+
+			// when
+			_, err := o.Call(context.Background(), []LLMMessage{NewLLMMessage(LLMMessageTypeUser, "hi")})
+
+			// then
+			require.NoError(t, err)
+			request := server.request(0)
+			assert.Equal(t, 512.0, request["max_output_tokens"])
+			assert.Equal(t, tt.wantTopP, request["top_p"])
+		})
+	}
+}
@@ -0,0 +1,440 @@
+package llm
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type sumToolResult struct {
+	BaseLLMToolResult
+	Sum float64 `json:"sum"`
+}
+
+// sseResponse encodes completion chunks as a server-sent events stream.
+func sseResponse(chunks ...string) string {
+	var b strings.Builder
+	for _, chunk := range chunks {
+		b.WriteString("data: " + chunk + "\n\n")
+	}
+	b.WriteString("data: [DONE]\n\n")
+	return b.String()
+}
+
+func newTestOpenAILLM(server *scriptedServer, options ...openAILLMOption) *openAILLM {
+	o := newOpenAILLM(append([]openAILLMOption{withOpenAILLMModel("gpt-4.1")}, options...)...)
+	o.client = openai.NewClient(
+		option.WithAPIKey("test"),
+		option.WithBaseURL(server.URL),
+		option.WithMaxRetries(0),
+	)
+	return o
+}
+
+const toolCallCompletion = `{
+	"id": "chatcmpl-1",
+	"object": "chat.completion",
+	"created": 1,
+	"model": "gpt-4.1",
+	"choices": [{
+		"index": 0,
+		"finish_reason": "tool_calls",
+		"message": {
+			"role": "assistant",
+			"content": null,
+			"tool_calls": [{
+				"id": "call_1",
+				"type": "function",
+				"function": {"name": "add", "arguments": "{\"num1\":3,\"num2\":5}"}
+			}]
+		}
+	}]
+}`
+
+const stopCompletion = `{
+	"id": "chatcmpl-2",
+	"object": "chat.completion",
+	"created": 2,
+	"model": "gpt-4.1-2025-04-14",
+	"choices": [{
+		"index": 0,
+		"finish_reason": "stop",
+		"message": {"role": "assistant", "content": "{\"sum\":8}"}
+	}],
+	"usage": {
+		"prompt_tokens": 120,
+		"completion_tokens": 30,
+		"total_tokens": 150,
+		"prompt_tokens_details": {"cached_tokens": 100},
+		"completion_tokens_details": {"reasoning_tokens": 20}
+	}
+}`
+
+func TestOpenAILLMRoundTripsToolCalls(t *testing.T) {
+	// given
+	server := newScriptedServer(t, toolCallCompletion, stopCompletion)
+	o := newTestOpenAILLM(server)
+	msgs := []LLMMessage{
+		NewLLMMessage(LLMMessageTypeSystem, "You are a calculator"),
+		NewLLMMessage(LLMMessageTypeUser, `{"num1":3,"num2":5}`),
+	}
+
+	// when
+	toolCallMsg, err := o.Call(context.Background(), msgs)
+	require.NoError(t, err)
+	require.Len(t, toolCallMsg.ToolCalls, 1)
+	toolCallMsg.ToolResults = []LLMToolResult{
+		sumToolResult{BaseLLMToolResult: BaseLLMToolResult{ID: "call_1"}, Sum: 8},
+	}
+	finalMsg, err := o.Call(context.Background(), append(msgs, toolCallMsg))
+
+	// then
+	require.NoError(t, err)
+	assert.True(t, finalMsg.End)
+	assert.Equal(t, `{"sum":8}`, finalMsg.Content)
+	assert.Equal(t, "gpt-4.1-2025-04-14", finalMsg.Model)
+	assert.Equal(t, LLMUsage{
+		PromptTokens:     120,
+		CompletionTokens: 30,
+		TotalTokens:      150,
+		CachedTokens:     100,
+		ReasoningTokens:  20,
+	}, finalMsg.Usage)
+
+	sent := server.request(1)["messages"].([]any)
+	require.Len(t, sent, 4)
+
+	assistant := sent[2].(map[string]any)
+	assert.Equal(t, "assistant", assistant["role"])
+	toolCalls := assistant["tool_calls"].([]any)
+	require.Len(t, toolCalls, 1)
+	toolCall := toolCalls[0].(map[string]any)
+	assert.Equal(t, "call_1", toolCall["id"])
+	function := toolCall["function"].(map[string]any)
+	assert.Equal(t, "add", function["name"])
+	assert.JSONEq(t, `{"num1":3,"num2":5}`, function["arguments"].(string))
+
+	tool := sent[3].(map[string]any)
+	assert.Equal(t, "tool", tool["role"])
+	assert.Equal(t, "call_1", tool["tool_call_id"])
+	assert.JSONEq(t, `{"id":"call_1","sum":8}`, tool["content"].(string))
+}
+
+func TestOpenAILLMSendsResponseSchemaWhenStructuredOutputsEnabled(t *testing.T) {
+	schema := map[string]any{
+		"type":                 "object",
+		"properties":           map[string]any{"sum": map[string]any{"type": "integer"}},
+		"required":             []string{"sum"},
+		"additionalProperties": false,
+	}
+	msgs := []LLMMessage{NewLLMMessage(LLMMessageTypeUser, `{"num1":3,"num2":5}`)}
+
+	t.Run("enabled", func(t *testing.T) {
+		// given
+		server := newScriptedServer(t, stopCompletion)
+		o := newTestOpenAILLM(server, withOpenAIStructuredOutputs(true))
+
+		// when
+		_, err := o.Call(context.Background(), msgs, WithLLMCallStrictResponseSchema("agent_result", schema))
+
+		// then
+		require.NoError(t, err)
+		responseFormat := server.request(0)["response_format"].(map[string]any)
+		assert.Equal(t, "json_schema", responseFormat["type"])
+		jsonSchema := responseFormat["json_schema"].(map[string]any)
+		assert.Equal(t, "agent_result", jsonSchema["name"])
+		assert.Equal(t, true, jsonSchema["strict"])
+		assert.Equal(t, "object", jsonSchema["schema"].(map[string]any)["type"])
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		// given
+		server := newScriptedServer(t, stopCompletion)
+		o := newTestOpenAILLM(server)
+
+		// when
+		_, err := o.Call(context.Background(), msgs, WithLLMCallStrictResponseSchema("agent_result", schema))
+
+		// then
+		require.NoError(t, err)
+		assert.NotContains(t, server.request(0), "response_format")
+	})
+}
+
+func TestOpenAILLMKeepsToolCallsWithMalformedArguments(t *testing.T) {
+	// given
+	malformed := `{
+		"id": "chatcmpl-3",
+		"object": "chat.completion",
+		"created": 3,
+		"model": "gpt-4.1",
+		"choices": [{
+			"index": 0,
+			"finish_reason": "tool_calls",
+			"message": {
+				"role": "assistant",
+				"content": null,
+				"tool_calls": [{
+					"id": "call_1",
+					"type": "function",
+					"function": {"name": "add", "arguments": "{\"num1\": 3,"}
+				}]
+			}
+		}]
+	}`
+	server := newScriptedServer(t, malformed)
+	o := newTestOpenAILLM(server)
+
+	// when
+	msg, err := o.Call(context.Background(), []LLMMessage{NewLLMMessage(LLMMessageTypeUser, "add 3 and 5")})
+
+	// then
+	require.NoError(t, err)
+	require.Len(t, msg.ToolCalls, 1)
+	assert.Nil(t, msg.ToolCalls[0].Args)
+	assert.Equal(t, `{"num1": 3,`, msg.ToolCalls[0].ArgsJSON())
+	_, err = msg.ToolCalls[0].ParseArgs()
+	assert.ErrorIs(t, err, ErrInvalidArguments)
+}
+
+func TestOpenAILLMStreamsContentDeltas(t *testing.T) {
+	// given
+	server := newScriptedServer(t, sseResponse(
+		`{"id":"c1","object":"chat.completion.chunk","created":1,"model":"gpt-4.1","choices":[{"index":0,"delta":{"role":"assistant","content":"{\"sum\":"}}]}`,
+		`{"id":"c1","object":"chat.completion.chunk","created":1,"model":"gpt-4.1","choices":[{"index":0,"delta":{"content":"8}"},"finish_reason":"stop"}]}`,
+		`{"id":"c1","object":"chat.completion.chunk","created":1,"model":"gpt-4.1","choices":[],"usage":{"prompt_tokens":120,"completion_tokens":30,"total_tokens":150,"prompt_tokens_details":{"cached_tokens":100},"completion_tokens_details":{"reasoning_tokens":20}}}`,
+	))
+	o := newTestOpenAILLM(server)
+
+	// when
+	stream, err := o.Stream(context.Background(), []LLMMessage{NewLLMMessage(LLMMessageTypeUser, "add 3 and 5")})
+	require.NoError(t, err)
+	defer stream.Close()
+
+	var deltas []string
+	for stream.Next() {
+		deltas = append(deltas, stream.Current().Delta)
+	}
+
+	// then
+	require.NoError(t, stream.Err())
+	assert.Equal(t, []string{`{"sum":`, `8}`}, deltas)
+
+	msg := stream.Message()
+	assert.Equal(t, `{"sum":8}`, msg.Content)
+	assert.True(t, msg.End)
+	assert.Equal(t, LLMUsage{PromptTokens: 120, CompletionTokens: 30, TotalTokens: 150, CachedTokens: 100, ReasoningTokens: 20}, msg.Usage)
+
+	request := server.request(0)
+	assert.Equal(t, true, request["stream"])
+	assert.Equal(t, map[string]any{"include_usage": true}, request["stream_options"])
+}
+
+func TestOpenAILLMStreamsToolCalls(t *testing.T) {
+	// given
+	server := newScriptedServer(t, sseResponse(
+		`{"id":"c2","object":"chat.completion.chunk","created":1,"model":"gpt-4.1","choices":[{"index":0,"delta":{"role":"assistant","tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"add","arguments":""}}]}}]}`,
+		`{"id":"c2","object":"chat.completion.chunk","created":1,"model":"gpt-4.1","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"{\"num1\":3,"}}]}}]}`,
+		`{"id":"c2","object":"chat.completion.chunk","created":1,"model":"gpt-4.1","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"num2\":5}"}}]},"finish_reason":"tool_calls"}]}`,
+	))
+	o := newTestOpenAILLM(server)
+
+	// when
+	stream, err := o.Stream(context.Background(), []LLMMessage{NewLLMMessage(LLMMessageTypeUser, "add 3 and 5")})
+	require.NoError(t, err)
+	defer stream.Close()
+
+	var assembled []string
+	for stream.Next() {
+		event := stream.Current()
+		require.Equal(t, LLMStreamEventTypeToolCall, event.Type)
+		assert.Equal(t, "call_1", event.ToolCall.ID)
+		assert.Equal(t, "add", event.ToolCall.ToolName)
+		assembled = append(assembled, event.ToolCall.RawArgs)
+	}
+
+	// then
+	require.NoError(t, stream.Err())
+	assert.Equal(t, []string{"", `{"num1":3,`, `{"num1":3,"num2":5}`}, assembled)
+
+	msg := stream.Message()
+	assert.False(t, msg.End)
+	require.Len(t, msg.ToolCalls, 1)
+	assert.Equal(t, map[string]any{"num1": 3.0, "num2": 5.0}, msg.ToolCalls[0].Args)
+}
+
+func TestOpenAILLMTargetsCompatibleServer(t *testing.T) {
+	// given
+	server := newScriptedServer(t, stopCompletion)
+	created, err := CreateLLM(LLMConfig{
+		Type:                  LLMTypeOpenAI,
+		APIKey:                "local",
+		Model:                 "llama3.1",
+		Temperature:           0.7,
+		BaseURL:               server.URL,
+		Organization:          "org-analytics",
+		Headers:               map[string]string{"X-Gateway-Team": "analytics"},
+		UnsupportedParameters: []string{"temperature"},
+	}, nil)
+	require.NoError(t, err)
+
+	// when
+	msg, err := created.Call(context.Background(), []LLMMessage{NewLLMMessage(LLMMessageTypeUser, "hi")})
+
+	// then
+	require.NoError(t, err)
+	assert.Equal(t, `{"sum":8}`, msg.Content)
+
+	request := server.request(0)
+	assert.Equal(t, "llama3.1", request["model"])
+	assert.NotContains(t, request, "temperature")
+
+	header := server.header(0)
+	assert.Equal(t, "Bearer local", header.Get("Authorization"))
+	assert.Equal(t, "org-analytics", header.Get("OpenAI-Organization"))
+	assert.Equal(t, "analytics", header.Get("X-Gateway-Team"))
+}
+
+func TestOpenAILLMAdaptsRequestsToReasoningModels(t *testing.T) {
+	msgs := []LLMMessage{
+		NewLLMMessage(LLMMessageTypeSystem, "You are a calculator"),
+		NewLLMMessage(LLMMessageTypeUser, `{"num1":3,"num2":5}`),
+	}
+
+	t.Run("reasoning model", func(t *testing.T) {
+		// given
+		server := newScriptedServer(t, stopCompletion)
+		o := newTestOpenAILLM(server, withOpenAILLMModel("o4-mini"), withOpenAILLMTemperature(0.7), withOpenAIReasoning(true, "high"))
+
+		// when
+		_, err := o.Call(context.Background(), msgs)
+
+		// then
+		require.NoError(t, err)
+		request := server.request(0)
+		assert.NotContains(t, request, "temperature")
+		assert.Equal(t, "high", request["reasoning_effort"])
+		assert.Equal(t, "developer", request["messages"].([]any)[0].(map[string]any)["role"])
+	})
+
+	t.Run("chat model", func(t *testing.T) {
+		// given
+		server := newScriptedServer(t, stopCompletion)
+		o := newTestOpenAILLM(server, withOpenAILLMTemperature(0.7))
+
+		// when
+		_, err := o.Call(context.Background(), msgs)
+
+		// then
+		require.NoError(t, err)
+		request := server.request(0)
+		assert.Equal(t, 0.7, request["temperature"])
+		assert.NotContains(t, request, "reasoning_effort")
+		assert.Equal(t, "system", request["messages"].([]any)[0].(map[string]any)["role"])
+	})
+}
+
+func TestOpenAILLMSendsSamplingParameters(t *testing.T) {
+	msgs := []LLMMessage{NewLLMMessage(LLMMessageTypeUser, `{"num1":3,"num2":5}`)}
+	seed := int64(42)
+	options := []openAILLMOption{
+		withOpenAIMaxTokens(512),
+		withOpenAITopP(0.9),
+		withOpenAISeed(&seed),
+		withOpenAIStop([]string{"END"}),
+		withOpenAIPenalties(0.5, -0.5),
+	}
+
+	t.Run("chat model", func(t *testing.T) {
+		// given
+		server := newScriptedServer(t, stopCompletion)
+		o := newTestOpenAILLM(server, options...)
+
+		// when
+		_, err := o.Call(context.Background(), msgs)
+
+		// then
+		require.NoError(t, err)
+		request := server.request(0)
+		assert.Equal(t, 512.0, request["max_tokens"])
+		assert.NotContains(t, request, "max_completion_tokens")
+		assert.Equal(t, 0.9, request["top_p"])
+		assert.Equal(t, 42.0, request["seed"])
+		assert.Equal(t, []any{"END"}, request["stop"])
+		assert.Equal(t, 0.5, request["presence_penalty"])
+		assert.Equal(t, -0.5, request["frequency_penalty"])
+	})
+
+	t.Run("reasoning model", func(t *testing.T) {
+		// given
+		server := newScriptedServer(t, stopCompletion)
+		o := newTestOpenAILLM(server, append(options, withOpenAIReasoning(true, "low"))...)
+
+		// when
+		_, err := o.Call(context.Background(), msgs)
+
+		// then
+		require.NoError(t, err)
+		request := server.request(0)
+		assert.Equal(t, 512.0, request["max_completion_tokens"])
+		assert.NotContains(t, request, "max_tokens")
+		assert.Equal(t, 42.0, request["seed"])
+		for _, parameter := range []string{"top_p", "stop", "presence_penalty", "frequency_penalty"} {
+			assert.NotContains(t, request, parameter)
+		}
+	})
+
+	t.Run("unset", func(t *testing.T) {
+		// given
+		server := newScriptedServer(t, stopCompletion)
+		o := newTestOpenAILLM(server)
+
+		// when
+		_, err := o.Call(context.Background(), msgs)
+
+		// then
+		require.NoError(t, err)
+		request := server.request(0)
+		for _, key := range []string{"max_tokens", "max_completion_tokens", "top_p", "seed", "stop", "presence_penalty", "frequency_penalty"} {
+			assert.NotContains(t, request, key)
+		}
+	})
+}
+
+func TestOpenAILLMTimesOutSlowRequests(t *testing.T) {
+	// given
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the server notices a closed connection once the body is read
+		_, _ = io.Copy(io.Discard, r.Body)
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	t.Cleanup(server.Close)
+	o, err := CreateLLM(LLMConfig{
+		Type:    LLMTypeOpenAI,
+		APIKey:  "test",
+		Model:   "gpt-4.1",
+		BaseURL: server.URL,
+		Timeout: 50 * time.Millisecond,
+	}, nil)
+	require.NoError(t, err)
+
+	// when
+	start := time.Now()
+	_, err = o.Call(context.Background(), []LLMMessage{NewLLMMessage(LLMMessageTypeUser, "hi")})
+
+	// then
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), time.Second)
+}
@@ -0,0 +1,74 @@
+package llm
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// scriptedServer is an offline stand-in for the HTTP API of a provider. It
+// replies with the scripted responses in order and records every request.
+type scriptedServer struct {
+	*httptest.Server
+	mu        sync.Mutex
+	responses []string
+	requests  []map[string]any
+	headers   []http.Header
+	urls      []string
+}
+
+func newScriptedServer(t *testing.T, responses ...string) *scriptedServer {
+	t.Helper()
+	s := &scriptedServer{responses: responses}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var request map[string]any
+		if err := json.Unmarshal(body, &request); err != nil {
+			t.Errorf("invalid request body: %s", body)
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.requests = append(s.requests, request)
+		s.headers = append(s.headers, r.Header.Clone())
+		s.urls = append(s.urls, r.URL.String())
+		if len(s.responses) == 0 {
+			t.Errorf("unexpected request: %s", body)
+			http.Error(w, "no scripted response", http.StatusInternalServerError)
+			return
+		}
+		response := s.responses[0]
+		s.responses = s.responses[1:]
+
+		if strings.HasPrefix(response, "data:") {
+			w.Header().Set("Content-Type", "text/event-stream")
+		} else {
+			w.Header().Set("Content-Type", "application/json")
+		}
+		_, _ = w.Write([]byte(response))
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *scriptedServer) request(i int) map[string]any {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[i]
+}
+
+func (s *scriptedServer) header(i int) http.Header {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.headers[i]
+}
+
+func (s *scriptedServer) url(i int) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.urls[i]
+}
@@ -0,0 +1,129 @@
+package tokenizer
+
+import (
+	"math"
+	"strings"
+	"unicode"
+)
+
+// Encoding counts the tokens of a text.
+type Encoding interface {
+	Name() string
+	Count(text string) int
+}
+
+// bpeEncoding approximates a byte pair encoding without its vocabulary. The
+// text is split like the encoding pre-tokenizer splits it, and every piece is
+// estimated from the average piece lengths of the encoding: short words are a
+// single token, digits are grouped by three and punctuation runs such as the
+// ":" and "," separators of JSON merge by three. The counts are tested against
+// tiktoken counts of English and JSON samples, but any single text may be off
+// in either direction.
+type bpeEncoding struct {
+	name string
+	// maxWordLen is the longest ASCII word, including its leading space,
+	// estimated as a single token.
+	maxWordLen int
+	// charsPerToken is the average ASCII characters per token of longer words,
+	// which are mostly made of a stem and common affixes.
+	charsPerToken float64
+	// runesPerToken is the average non-ASCII letters per token.
+	runesPerToken float64
+}
+
+var (
+	// CL100KBaseEstimate approximates the cl100k_base encoding of the GPT-4 and
+	// GPT-3.5 models. It is not the tiktoken encoding.
+	CL100KBaseEstimate Encoding = &bpeEncoding{name: "cl100k_base_estimate", maxWordLen: 9, charsPerToken: 6, runesPerToken: 1}
+	// O200KBaseEstimate approximates the o200k_base encoding of the GPT-4o,
+	// GPT-4.1, GPT-5 and o-series models. It is not the tiktoken encoding.
+	O200KBaseEstimate Encoding = &bpeEncoding{name: "o200k_base_estimate", maxWordLen: 10, charsPerToken: 7, runesPerToken: 1.5}
+	// Heuristic is used for models without a known encoding.
+	Heuristic Encoding = heuristicEncoding{}
+)
+
+func (e *bpeEncoding) Name() string {
+	return e.name
+}
+
+func (e *bpeEncoding) Count(text string) int {
+	runes := []rune(text)
+	tokens := 0
+	for i := 0; i < len(runes); {
+		start := i
+		switch r := runes[i]; {
+		case isLetter(r) || (r == ' ' && i+1 < len(runes) && isLetter(runes[i+1])):
+			i++
+			for i < len(runes) && isLetter(runes[i]) {
+				i++
+			}
+			tokens += e.countWord(runes[start:i])
+		case unicode.IsDigit(r):
+			for i < len(runes) && unicode.IsDigit(runes[i]) {
+				i++
+			}
+			tokens += ceilDiv(i-start, 3)
+		case unicode.IsSpace(r):
+			for i < len(runes) && unicode.IsSpace(runes[i]) {
+				i++
+			}
+			// a single space is merged into the following piece
+			if i-start == 1 && r == ' ' && i < len(runes) {
+				continue
+			}
+			tokens++
+		default:
+			i++
+			for i < len(runes) && isPunct(runes[i]) {
+				i++
+			}
+			tokens += ceilDiv(i-start, 3)
+		}
+	}
+	return tokens
+}
+
+func (e *bpeEncoding) countWord(word []rune) int {
+	ascii, other := 0, 0
+	for _, r := range word {
+		if r <= unicode.MaxASCII {
+			ascii++
+		} else {
+			other++
+		}
+	}
+
+	tokens := int(math.Ceil(float64(other) / e.runesPerToken))
+	switch {
+	case ascii == 0 || (ascii == 1 && word[0] == ' ' && other > 0):
+	case ascii <= e.maxWordLen:
+		tokens++
+	default:
+		tokens += int(math.Ceil(float64(ascii) / e.charsPerToken))
+	}
+	return tokens
+}
+
+func isLetter(r rune) bool {
+	return unicode.IsLetter(r) || unicode.Is(unicode.Mn, r)
+}
+
+func isPunct(r rune) bool {
+	return !isLetter(r) && !unicode.IsDigit(r) && !unicode.IsSpace(r)
+}
+
+func ceilDiv(a, b int) int {
+	return (a + b - 1) / b
+}
+
+// heuristicEncoding estimates four characters per token, which holds on
+// average for English text in most tokenizers.
+type heuristicEncoding struct{}
+
+func (heuristicEncoding) Name() string {
+	return "heuristic"
+}
+
+func (heuristicEncoding) Count(text string) int {
+	return ceilDiv(len([]rune(strings.TrimSpace(text))), 4)
+}
@@ -0,0 +1,105 @@
+// Package tokenizer counts the tokens of LLM requests offline, before they are
+// sent. The counts are estimates: they are meant for context window management,
+// cost estimates and sampling, not for billing.
+package tokenizer
+
+import (
+	"encoding/json"
+	"strings"
+
+	"reddit-analyzer/internal/agent/llm"
+)
+
+// The overheads of the chat format, as documented for OpenAI chat models.
+const (
+	tokensPerMessage  = 3
+	tokensPerReply    = 3
+	tokensPerToolCall = 3
+	tokensPerTools    = 12
+	tokensPerTool     = 8
+)
+
+// modelEncodings maps model name prefixes to the estimates of their encodings.
+// Longer prefixes are matched first.
+var modelEncodings = []struct {
+	prefix   string
+	encoding Encoding
+}{
+	{prefix: "gpt-4o", encoding: O200KBaseEstimate},
+	{prefix: "gpt-4.1", encoding: O200KBaseEstimate},
+	{prefix: "gpt-4.5", encoding: O200KBaseEstimate},
+	{prefix: "gpt-5", encoding: O200KBaseEstimate},
+	{prefix: "chatgpt-4o", encoding: O200KBaseEstimate},
+	{prefix: "o1", encoding: O200KBaseEstimate},
+	{prefix: "o3", encoding: O200KBaseEstimate},
+	{prefix: "o4", encoding: O200KBaseEstimate},
+	{prefix: "gpt-4", encoding: CL100KBaseEstimate},
+	{prefix: "gpt-3.5", encoding: CL100KBaseEstimate},
+	{prefix: "text-embedding-3", encoding: CL100KBaseEstimate},
+	{prefix: "text-embedding-ada-002", encoding: CL100KBaseEstimate},
+}
+
+// EncodingForModel returns the encoding of the model. Unknown models return
+// the Heuristic encoding and false.
+func EncodingForModel(model string) (Encoding, bool) {
+	for _, m := range modelEncodings {
+		if strings.HasPrefix(model, m.prefix) {
+			return m.encoding, true
+		}
+	}
+	return Heuristic, false
+}
+
+type Tokenizer struct {
+	encoding Encoding
+}
+
+func New(encoding Encoding) *Tokenizer {
+	return &Tokenizer{encoding: encoding}
+}
+
+// ForModel creates a tokenizer with the encoding of the model, falling back to
+// the Heuristic encoding for unknown models.
+func ForModel(model string) *Tokenizer {
+	encoding, _ := EncodingForModel(model)
+	return New(encoding)
+}
+
+func (t *Tokenizer) Encoding() Encoding {
+	return t.encoding
+}
+
+func (t *Tokenizer) Count(text string) int {
+	return t.encoding.Count(text)
+}
+
+// CountMessages counts the prompt tokens of the messages, including the tool
+// calls and the tool results, which are sent as separate tool messages.
+func (t *Tokenizer) CountMessages(msgs []llm.LLMMessage) int {
+	tokens := tokensPerReply
+	for _, msg := range msgs {
+		tokens += tokensPerMessage + t.Count(string(msg.Type)) + t.Count(msg.Content)
+		for _, toolCall := range msg.ToolCalls {
+			tokens += tokensPerToolCall + t.Count(toolCall.ID) + t.Count(toolCall.ToolName) + t.Count(toolCall.ArgsJSON())
+		}
+		for _, result := range msg.ToolResults {
+			content, _ := json.Marshal(result)
+			tokens += tokensPerMessage + t.Count(result.GetID()) + t.Count(string(content))
+		}
+	}
+	return tokens
+}
+
+// CountTools counts the tokens the tool schemas add to every request.
+func (t *Tokenizer) CountTools(tools []llm.LLMTool) int {
+	if len(tools) == 0 {
+		return 0
+	}
+
+	tokens := tokensPerTools
+	for _, tool := range tools {
+		parameters, _ := json.Marshal(tool.ParametersSchema)
+		tokens += tokensPerTool + t.Count(tool.Name) + t.Count(tool.Description) + t.Count(string(parameters))
+	}
+	return tokens
+}
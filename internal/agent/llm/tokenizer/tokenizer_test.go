@@ -0,0 +1,143 @@
+package tokenizer_test
+
+import (
+	"testing"
+
+	"reddit-analyzer/internal/agent/llm"
+	"reddit-analyzer/internal/agent/llm/tokenizer"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncodingForModel(t *testing.T) {
+	tests := []struct {
+		model     string
+		want      tokenizer.Encoding
+		wantKnown bool
+	}{
+		{model: "gpt-4o-mini", want: tokenizer.O200KBaseEstimate, wantKnown: true},
+		{model: "gpt-4.1-2025-04-14", want: tokenizer.O200KBaseEstimate, wantKnown: true},
+		{model: "o3-mini", want: tokenizer.O200KBaseEstimate, wantKnown: true},
+		{model: "gpt-4-turbo", want: tokenizer.CL100KBaseEstimate, wantKnown: true},
+		{model: "gpt-3.5-turbo", want: tokenizer.CL100KBaseEstimate, wantKnown: true},
+		{model: "claude-sonnet-4", want: tokenizer.Heuristic},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.model, func(t *testing.T) {
+			// when
+			encoding, ok := tokenizer.EncodingForModel(tt.model)
+
+			// then
+			assert.Equal(t, tt.wantKnown, ok)
+			assert.Equal(t, tt.want.Name(), encoding.Name())
+		})
+	}
+}
+
+func TestEncodingCountMatchesTiktoken(t *testing.T) {
+	// the counts of tiktoken, which are the same for cl100k_base and
+	// o200k_base on these samples
+	samples := []struct {
+		text string
+		want int
+	}{
+		{text: "hello world", want: 2},
+		{text: "Hello, world!", want: 4},
+		{text: "The quick brown fox jumps over the lazy dog.", want: 10},
+		{text: "The comments were mostly positive about the new release.", want: 10},
+		{text: "internationalization", want: 2},
+		{text: "antidisestablishmentarianism", want: 6},
+		{text: "1234567", want: 3},
+		{text: "2024-01-15", want: 6},
+		{text: `{"sum":8}`, want: 5},
+		{text: `{"id":"call_1","sum":3}`, want: 11},
+		{text: `{"title":"Hello world","score":42}`, want: 10},
+	}
+
+	for _, encoding := range []tokenizer.Encoding{tokenizer.CL100KBaseEstimate, tokenizer.O200KBaseEstimate} {
+		t.Run(encoding.Name(), func(t *testing.T) {
+			total, wantTotal := 0, 0
+			for _, sample := range samples {
+				// when
+				count := encoding.Count(sample.text)
+
+				// then
+				// every sample is within 2 tokens or 20%, whichever is larger
+				tolerance := max(2, float64(sample.want)*0.2)
+				assert.InDelta(t, sample.want, count, tolerance, sample.text)
+				total += count
+				wantTotal += sample.want
+			}
+			// and all samples together are within 10%
+			assert.InDelta(t, wantTotal, total, float64(wantTotal)*0.1)
+		})
+	}
+}
+
+func TestEncodingCountIsPlausible(t *testing.T) {
+	texts := []string{
+		"hello world",
+		"internationalization",
+		"1234567",
+		`{"sum":8}`,
+		"first\n\nsecond",
+		"привіт",
+		"The quick brown fox jumps over the lazy dog.",
+	}
+
+	for _, encoding := range []tokenizer.Encoding{tokenizer.CL100KBaseEstimate, tokenizer.O200KBaseEstimate, tokenizer.Heuristic} {
+		t.Run(encoding.Name(), func(t *testing.T) {
+			assert.Zero(t, encoding.Count(""))
+			for _, text := range texts {
+				// when
+				count := encoding.Count(text)
+				doubled := encoding.Count(text + " " + text)
+
+				// then
+				runes := len([]rune(text))
+				assert.GreaterOrEqual(t, count, 1, text)
+				assert.LessOrEqual(t, count, runes, text)
+				assert.Greater(t, doubled, count, text)
+			}
+		})
+	}
+}
+
+func TestTokenizerCountMessages(t *testing.T) {
+	// given
+	tok := tokenizer.ForModel("gpt-4o")
+	toolCall := llm.LLMMessage{
+		Type:      llm.LLMMessageTypeAssistant,
+		ToolCalls: []llm.LLMToolCall{llm.NewRawLLMToolCall("call_1", "add", `{"num1":3}`)},
+		ToolResults: []llm.LLMToolResult{
+			llm.LLMRawToolResult{ID: "call_1", Raw: []byte(`{"id":"call_1","sum":3}`)},
+		},
+	}
+	msgs := []llm.LLMMessage{llm.NewLLMMessage(llm.LLMMessageTypeUser, "hello world")}
+
+	// when
+	plain := tok.CountMessages(msgs)
+	withTools := tok.CountMessages(append(msgs, toolCall))
+
+	// then
+	assert.Equal(t, 3+3+tok.Count("user")+tok.Count("hello world"), plain)
+	assert.Greater(t, withTools, plain+tok.Count(`{"id":"call_1","sum":3}`))
+}
+
+func TestTokenizerCountTools(t *testing.T) {
+	// given
+	tok := tokenizer.ForModel("gpt-4o")
+	tool := llm.NewLLMTool(
+		llm.WithLLMToolName("add"),
+		llm.WithLLMToolDescription("Adds two numbers"),
+		llm.WithLLMToolParametersSchema(map[string]any{"type": "object"}),
+	)
+
+	// when
+	count := tok.CountTools([]llm.LLMTool{tool})
+
+	// then
+	assert.Zero(t, tok.CountTools(nil))
+	assert.Equal(t, 12+8+tok.Count("add")+tok.Count("Adds two numbers")+tok.Count(`{"type":"object"}`), count)
+}